@@ -44,9 +44,13 @@ type App struct {
 	settingsStore *SettingsStore
 	mu            sync.RWMutex
 	RemoteManager *remote.RemoteManager
+	downloadQueue *DownloadQueue
 
 	translationCancel context.CancelFunc
 	translationMu     sync.Mutex
+
+	exportCancel context.CancelFunc
+	exportMu     sync.Mutex
 }
 
 func (a *App) createApplication() {
@@ -71,6 +75,7 @@ func NewApp() *App {
 		settingsStore: NewSettingsStore(),
 		RemoteManager: remote.NewManager(true),
 	}
+	app.downloadQueue = NewDownloadQueue(app.RemoteManager)
 	app.httpServer = NewHTTPServer(app)
 	return app
 }
@@ -83,6 +88,14 @@ func (a *App) startup(ctx context.Context) {
 	// Start media server
 	go a.mediaServer.Start()
 
+	// Probe ffmpeg for the configured encoder without blocking startup;
+	// transcodes use libx264 until the probe completes.
+	go ffmpeg.SelectEncoder(a.settingsStore.Get().VideoEncoder)
+	ffmpeg.SetMaxProcesses(a.settingsStore.Get().MaxFFmpegProcesses)
+	remote.SetDownloadParallelism(a.settingsStore.Get().DownloadParallelism)
+	a.applyDownloadSchedule(a.settingsStore.Get())
+	a.downloadQueue.Start()
+
 	// Start remote control HTTP API if enabled. Wire the library scanner in so
 	// the /library endpoint serves real items instead of the history fallback.
 	a.httpServer.SetLibraryLister(a)
@@ -122,11 +135,22 @@ func (a *App) GetTrackDisplayInfo(fileNameOrUrl string) (*TrackDisplayInfo, erro
 	var err error
 	remote := strings.HasPrefix(fileNameOrUrl, "http://") || strings.HasPrefix(fileNameOrUrl, "https://")
 	if remote {
-		mediaManager, err := a.RemoteManager.GetMedia(fileNameOrUrl)
+		progressive, err := a.RemoteManager.GetProgressive(fileNameOrUrl)
 		if err != nil {
 			return nil, err
 		}
-		trackInfo = mediaManager.Manifest
+		if progressive != nil {
+			trackInfo, err = ffmpeg.GetMediaTrackInfo(progressive.LocalURL())
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			mediaManager, err := a.RemoteManager.GetMedia(fileNameOrUrl)
+			if err != nil {
+				return nil, err
+			}
+			trackInfo = mediaManager.Playlist()
+		}
 	} else {
 		trackInfo, err = ffmpeg.GetMediaTrackInfo(fileNameOrUrl)
 	}
@@ -146,8 +170,9 @@ func (a *App) GetTrackDisplayInfo(fileNameOrUrl string) (*TrackDisplayInfo, erro
 
 	for _, sub := range trackInfo.SubtitleTracks {
 		subtitleItems = append(subtitleItems, SubtitleDisplayItem{
-			Path:  fmt.Sprintf("embedded:%d", sub.Index),
-			Label: fmt.Sprintf("Embedded: %s", sub.Name),
+			Path:       fmt.Sprintf("embedded:%d", sub.Index),
+			Label:      fmt.Sprintf("Embedded: %s", sub.Name),
+			BurnInOnly: ffmpeg.IsBitmapSubtitle(sub.Codec),
 		})
 	}
 
@@ -188,30 +213,59 @@ func (a *App) CastToDevice(deviceIp string, fileNameOrUrl string, castOptions *o
 	// Determine if input is a local file or remote URL
 	isRemote := strings.HasPrefix(fileNameOrUrl, "http://") || strings.HasPrefix(fileNameOrUrl, "https://")
 	settings := a.GetSettings()
+	deviceKey := a.discovery.DeviceKey(deviceIp)
 	options := options.StreamOptions{
-		Subtitle: options.SubtitleCastOptions{
-			Path:                 castOptions.SubtitlePath,
-			BurnIn:               settings.SubtitleBurnIn,
-			FontSize:             settings.SubtitleFontSize,
-			IgnoreClosedCaptions: settings.IgnoreClosedCaptions,
-			DelaySeconds:         settings.SubtitleDelaySeconds,
-			Bold:                 settings.SubtitleBold,
-			Italic:               settings.SubtitleItalic,
-		},
+		Subtitle:         settings.subtitle(castOptions.SubtitlePath),
 		VideoTrack:       castOptions.VideoTrack,
 		AudioTrack:       castOptions.AudioTrack,
 		Bitrate:          castOptions.Bitrate,
 		MaxOutputWidth:   settings.MaxOutputWidth,
 		NoTranscodeCache: settings.NoTranscodeCache,
+		DirectPlay:       settings.DirectPlay,
+		Container:        settings.SegmentContainer,
+		Renditions:       settings.renditions(),
+		Picture:          settings.picture(castOptions),
+		Audio: options.AudioOptions{
+			Mode:          settings.audioMode(deviceKey, deviceIp),
+			Loudnorm:      settings.AudioLoudnorm,
+			NightMode:     settings.AudioNightMode,
+			DialogueBoost: settings.AudioDialogueBoost,
+			Bitrate:       settings.AudioBitrate,
+		},
+		Capabilities: options.DefaultCapabilities.WithAudioMode(settings.audioMode(deviceKey, deviceIp)).WithHDR(settings.keepHDR(deviceKey, deviceIp)),
 	}
 	var duration float64
 	var err error
 	var name string
 
+	a.mediaServer.SetPrefetcher(settings.prefetcher())
+
 	host := deviceIp
 	port := 8009
 
+	var progressive *remote.ProgressiveSource
 	if isRemote {
+		progressive, err = a.RemoteManager.GetProgressive(fileNameOrUrl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to probe remote media: %w", err)
+		}
+	}
+
+	if progressive != nil {
+		// Plain media files are segmented and transcoded like local files
+		logger.Info("Preparing progressive stream", "url", fileNameOrUrl)
+		name = progressive.Name()
+		duration, err = ffmpeg.GetVideoDuration(progressive.LocalURL())
+		if err != nil {
+			logger.Warn("Failed to get duration", "error", err)
+			duration = 0
+		}
+
+		handler := stream.NewProgressiveHandler(progressive, options)
+		a.mediaServer.SetHandler(handler)
+		options.Subtitle = handler.Options.Subtitle
+		a.mediaServer.SetSubtitlePath(options.Subtitle.Path)
+	} else if isRemote {
 		// Use CastManager to prepare remote stream
 		logger.Info("Preparing remote stream", "url", fileNameOrUrl)
 		manager, err := a.RemoteManager.GetMedia(fileNameOrUrl)
@@ -242,6 +296,8 @@ func (a *App) CastToDevice(deviceIp string, fileNameOrUrl string, castOptions *o
 		// Create local handler
 		handler := stream.NewLocalHandler(mediaPath, options)
 		a.mediaServer.SetHandler(handler)
+		// The handler flags image-based subtitles, which are always burned in
+		options.Subtitle = handler.Options.Subtitle
 
 		// Set subtitle path (for legacy/compatibility, though options has it)
 		a.mediaServer.SetSubtitlePath(options.Subtitle.Path)
@@ -307,7 +363,7 @@ func (a *App) CastToDevice(deviceIp string, fileNameOrUrl string, castOptions *o
 	// Add to history
 	a.historyStore.Add(fileNameOrUrl, name, castOptions)
 
-	if options.Subtitle.Path != "none" && !settings.SubtitleBurnIn {
+	if options.Subtitle.Path != "none" && !settings.SubtitleBurnIn && !options.Subtitle.Bitmap {
 		a.sendSubtitles(fmt.Sprintf("http://%s:%d/subtitles.vtt", a.localIp, a.port))
 	}
 
@@ -419,6 +475,9 @@ func (a *App) DeleteTranscodedCache() error {
 	if err := a.RemoteManager.StopAllAndClear(); err != nil {
 		return err
 	}
+	if err := a.downloadQueue.Clear(); err != nil {
+		return err
+	}
 	return folders.DeleteTranscodedCache()
 }
 
@@ -427,6 +486,9 @@ func (a *App) DeleteAllVideoCache() error {
 	if err := a.RemoteManager.StopAllAndClear(); err != nil {
 		return err
 	}
+	if err := a.downloadQueue.Clear(); err != nil {
+		return err
+	}
 	return folders.DeleteAllVideoCache()
 }
 
@@ -443,13 +505,60 @@ func (a *App) Export() AppExports {
 }
 
 func (a *App) StartDownload(url string, mediaType string, index int) error {
-	return a.RemoteManager.StartDownload(url, mediaType, index)
+	return a.downloadQueue.StartDirect(url, mediaType, index, func() error {
+		return a.RemoteManager.StartDownload(url, mediaType, index)
+	})
 }
 
 func (a *App) StopDownload(url string, mediaType string, index int) error {
 	return a.RemoteManager.StopDownload(url, mediaType, index)
 }
 
+// RetryFailedDownloads downloads again the segments of a track that failed
+func (a *App) RetryFailedDownloads(url string, mediaType string, index int) error {
+	return a.downloadQueue.StartDirect(url, mediaType, index, func() error {
+		return a.RemoteManager.RetryFailed(url, mediaType, index)
+	})
+}
+
+// SetDownloadRateLimit caps the background downloads of a remote media in
+// KB/s (0 = only the global limit)
+func (a *App) SetDownloadRateLimit(url string, kbps int) error {
+	return a.RemoteManager.SetRateLimit(url, kbps)
+}
+
+// GetDownloadQueue returns the persisted download queue in priority order
+func (a *App) GetDownloadQueue() []DownloadQueueItem {
+	return a.downloadQueue.GetAll()
+}
+
+// EnqueueDownload adds a track to the download queue; it starts when fewer
+// than maxQueuedDownloads queued tracks are downloading
+func (a *App) EnqueueDownload(url string, mediaType string, index int) error {
+	return a.downloadQueue.Add(url, mediaType, index)
+}
+
+// MoveQueuedDownload moves a queued track to position (0 = next to download)
+func (a *App) MoveQueuedDownload(url string, mediaType string, index int, position int) error {
+	return a.downloadQueue.Move(url, mediaType, index, position)
+}
+
+// PauseQueuedDownload stops a queued track until it is resumed
+func (a *App) PauseQueuedDownload(url string, mediaType string, index int) error {
+	return a.downloadQueue.Pause(url, mediaType, index)
+}
+
+// ResumeQueuedDownload queues a paused or failed track again
+func (a *App) ResumeQueuedDownload(url string, mediaType string, index int) error {
+	return a.downloadQueue.Resume(url, mediaType, index)
+}
+
+// RemoveQueuedDownload stops a track and removes it from the queue, keeping
+// its downloaded segments
+func (a *App) RemoveQueuedDownload(url string, mediaType string, index int) error {
+	return a.downloadQueue.Remove(url, mediaType, index)
+}
+
 // GetDownloadStatus returns the current download progress for a specific track
 func (a *App) GetDownloadStatus(url string, mediaType string, track int) (*remote.DownloadStatusQeuryResponse, error) {
 	return a.RemoteManager.GetDownloadStatus(url, mediaType, track)
@@ -562,6 +671,14 @@ func (a *App) OpenFileDialog(title string, filters []string) (string, error) {
 	})
 }
 
+// OpenExportFolderDialog shows a native folder picker for the target of an
+// export and returns the chosen path ("" if cancelled)
+func (a *App) OpenExportFolderDialog() (string, error) {
+	return wails_runtime.OpenDirectoryDialog(a.ctx, wails_runtime.OpenDialogOptions{
+		Title: "Select Export Folder",
+	})
+}
+
 // LogInfo logs an info message from frontend
 func (a *App) LogInfo(message string, args ...any) {
 	logger.Info(message, args...)
@@ -667,6 +784,68 @@ func (a *App) TranslateExportedSubtitles(fileNameOrUrl string, targetLanguage st
 	return nil
 }
 
+// ExportRemoteMedia writes the downloaded tracks of a remote stream with the
+// chosen subtitles into one MP4 or MKV file in folder. It runs in the
+// background, reporting progress via the "export:progress" event and the
+// outcome via "export:complete", "export:error" or "export:cancelled".
+func (a *App) ExportRemoteMedia(url string, castOptions *options.CastOptions, folder string, format string) error {
+	media, err := a.RemoteManager.GetMedia(url)
+	if err != nil {
+		return err
+	}
+
+	a.exportMu.Lock()
+	if a.exportCancel != nil {
+		a.exportMu.Unlock()
+		return fmt.Errorf("an export is already in progress")
+	}
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.exportCancel = cancel
+	a.exportMu.Unlock()
+
+	opts := stream.ExportOptions{
+		VideoTrack: castOptions.VideoTrack,
+		AudioTrack: castOptions.AudioTrack,
+		Subtitle:   a.GetSettings().subtitle(castOptions.SubtitlePath),
+		Folder:     folder,
+		Format:     format,
+	}
+
+	go func() {
+		defer func() {
+			a.exportMu.Lock()
+			a.exportCancel = nil
+			a.exportMu.Unlock()
+			cancel()
+		}()
+
+		path, err := stream.ExportRemote(ctx, media, opts, func(progress float64) {
+			events.Emit("export:progress", &stream.ExportProgress{URL: url, Progress: progress})
+		})
+		if err != nil {
+			if ctx.Err() == context.Canceled {
+				events.Emit("export:cancelled", url)
+			} else {
+				events.Emit("export:error", err.Error())
+			}
+			return
+		}
+		events.Emit("export:complete", path)
+	}()
+
+	return nil
+}
+
+// CancelExport aborts a running export, if any
+func (a *App) CancelExport() {
+	a.exportMu.Lock()
+	cancel := a.exportCancel
+	a.exportMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
 // CancelTranslation aborts an in-progress background translation, if any.
 func (a *App) CancelTranslation() {
 	a.translationMu.Lock()
@@ -722,7 +901,24 @@ func (a *App) GetSettings() *Settings {
 
 // UpdateSettings updates the settings
 func (a *App) UpdateSettings(settings Settings) error {
-	return a.settingsStore.Update(settings)
+	previous := *a.settingsStore.Get()
+	if _, err := remote.ParseDownloadWindow(settings.DownloadWindowStart, settings.DownloadWindowEnd); err != nil {
+		return err
+	}
+	if err := a.settingsStore.Update(settings); err != nil {
+		return err
+	}
+	if settings.VideoEncoder != previous.VideoEncoder {
+		ffmpeg.SelectEncoder(settings.VideoEncoder)
+	}
+	if settings.MaxFFmpegProcesses != previous.MaxFFmpegProcesses {
+		ffmpeg.SetMaxProcesses(settings.MaxFFmpegProcesses)
+	}
+	if settings.DownloadParallelism != previous.DownloadParallelism {
+		remote.SetDownloadParallelism(settings.DownloadParallelism)
+	}
+	a.applyDownloadSchedule(&settings)
+	return nil
 }
 
 // ResetSettings resets settings to defaults
@@ -730,7 +926,21 @@ func (a *App) ResetSettings() (*Settings, error) {
 	if err := a.settingsStore.Reset(); err != nil {
 		return nil, err
 	}
-	return a.settingsStore.Get(), nil
+	settings := a.settingsStore.Get()
+	ffmpeg.SelectEncoder(settings.VideoEncoder)
+	ffmpeg.SetMaxProcesses(settings.MaxFFmpegProcesses)
+	remote.SetDownloadParallelism(settings.DownloadParallelism)
+	a.applyDownloadSchedule(settings)
+	return settings, nil
+}
+
+// applyDownloadSchedule applies the rate limit and the download window of
+// background downloads
+func (a *App) applyDownloadSchedule(settings *Settings) {
+	remote.SetDownloadRateLimit(settings.DownloadRateLimit)
+	if err := remote.SetDownloadWindow(settings.DownloadWindowStart, settings.DownloadWindowEnd); err != nil {
+		logger.Warn("Invalid download window", "error", err)
+	}
 }
 
 // UpdateSettings updates the settings and applies remote API changes immediately.
@@ -887,6 +887,7 @@ func (u *ui) showSubtitleDialog() {
 			DelaySeconds:         delay.Value,
 			Bold:                 bold.Checked,
 			Italic:               italic.Checked,
+			ForceStyle:           u.currentSubtitleOpts.ForceStyle,
 		}
 		u.currentSubtitleOpts = next
 		u.currentSubtitlePath = next.Path
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 	"wails-cast/pkg/events"
 
@@ -21,10 +22,33 @@ type Device struct {
 	UUID    string `json:"uuid"`
 }
 
-type DeviceDiscovery struct{}
+type DeviceDiscovery struct {
+	mu sync.Mutex
+	// found maps the host of every device discovered so far to it
+	found map[string]Device
+}
 
 func NewDeviceDiscovery() *DeviceDiscovery {
-	return &DeviceDiscovery{}
+	return &DeviceDiscovery{found: make(map[string]Device)}
+}
+
+// remember records a discovered device for DeviceKey
+func (dd *DeviceDiscovery) remember(device Device) {
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	dd.found[device.Host] = device
+}
+
+// DeviceKey returns the key of the per-device settings of a host: the UUID
+// of the device found there, which survives a new DHCP lease, or the host
+// itself for devices without one
+func (dd *DeviceDiscovery) DeviceKey(host string) string {
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	if device, found := dd.found[host]; found && device.UUID != "" {
+		return device.UUID
+	}
+	return host
 }
 
 func (dd *DeviceDiscovery) DiscoverStream() error {
@@ -52,6 +76,7 @@ func (dd *DeviceDiscovery) DiscoverStream() error {
 				UUID:    entry.UUID,
 			}
 			devices = append(devices, device)
+			dd.remember(device)
 			logger.Info("Found device", "name", device.Name, "host", device.Host, "port", device.Port, "uuid", device.UUID)
 			// Emit device found event via backend event bus
 			events.Emit("device:found", device)
@@ -85,7 +110,7 @@ func (dd *DeviceDiscovery) DiscoverSync(timeout time.Duration) []Device {
 			continue
 		}
 		seen[entry.UUID] = true
-		devices = append(devices, Device{
+		device := Device{
 			Name:    entry.DeviceName,
 			Type:    "Chromecast",
 			Host:    entry.AddrV4.String(),
@@ -93,7 +118,9 @@ func (dd *DeviceDiscovery) DiscoverSync(timeout time.Duration) []Device {
 			Address: entry.AddrV4.String(),
 			URL:     fmt.Sprintf("http://%s:%d", entry.AddrV4.String(), entry.Port),
 			UUID:    entry.UUID,
-		})
+		}
+		devices = append(devices, device)
+		dd.remember(device)
 	}
 	return devices
 }
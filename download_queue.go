@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"wails-cast/pkg/events"
+	"wails-cast/pkg/folders"
+	"wails-cast/pkg/remote"
+)
+
+const (
+	downloadQueueFileName = "download_queue.json"
+	// maxQueuedDownloads is how many queue entries download at once
+	maxQueuedDownloads = 2
+	// queueRecheck is how often the running entries are compared with the
+	// status of their tracks
+	queueRecheck = time.Minute
+)
+
+// Statuses of a download queue entry
+const (
+	QueueStatusQueued      = "QUEUED"
+	QueueStatusDownloading = "DOWNLOADING"
+	QueueStatusPaused      = "PAUSED"
+	QueueStatusCompleted   = "COMPLETED"
+	QueueStatusError       = "ERROR"
+)
+
+type DownloadQueueItem struct {
+	URL       string `json:"url"`
+	MediaType string `json:"mediaType"`
+	Track     int    `json:"track"`
+	// Priority is the position in the queue, 0 downloads first
+	Priority int    `json:"priority"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// trackDownloader starts and stops the downloads of remote tracks
+type trackDownloader interface {
+	StartDownload(url string, mediaType string, index int) error
+	StopDownload(url string, mediaType string, index int) error
+	// TrackStatus reports the status of a loaded track, false when the
+	// track is not loaded
+	TrackStatus(url string, mediaType string, index int) (*remote.DownloadStatusQeuryResponse, bool)
+}
+
+// DownloadQueue keeps the remote track downloads in the config folder so they
+// survive a restart. Queued entries start in priority order, at most
+// maxQueuedDownloads at a time.
+type DownloadQueue struct {
+	items      []DownloadQueueItem
+	filePath   string
+	downloader trackDownloader
+	mu         sync.Mutex
+	// starting holds the entries whose download is being started
+	starting map[string]bool
+	// wake asks run to schedule the queue
+	wake chan struct{}
+}
+
+func NewDownloadQueue(downloader trackDownloader) *DownloadQueue {
+	appConfigDir := folders.GetConfig()
+	os.MkdirAll(appConfigDir, 0755)
+
+	queue := &DownloadQueue{
+		items:      []DownloadQueueItem{},
+		filePath:   filepath.Join(appConfigDir, downloadQueueFileName),
+		downloader: downloader,
+		starting:   make(map[string]bool),
+		wake:       make(chan struct{}, 1),
+	}
+
+	if err := queue.load(); err != nil {
+		logger.Warn("Failed to load download queue", "error", err)
+	}
+	return queue
+}
+
+func (q *DownloadQueue) load() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	data, err := os.ReadFile(q.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // No queue file yet
+		}
+		return err
+	}
+
+	return json.Unmarshal(data, &q.items)
+}
+
+func (q *DownloadQueue) save(items []DownloadQueueItem) error {
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(q.filePath, data, 0644)
+}
+
+// update applies change under the lock and, when it reports a change,
+// persists the queue and notifies the frontend. The event is emitted after
+// unlocking since the queue itself listens to events.
+func (q *DownloadQueue) update(change func() bool) error {
+	q.mu.Lock()
+	if !change() {
+		q.mu.Unlock()
+		return nil
+	}
+	items := q.snapshot()
+	err := q.save(items)
+	q.mu.Unlock()
+
+	events.Emit("download-queue:updated", items)
+	return err
+}
+
+func (q *DownloadQueue) snapshot() []DownloadQueueItem {
+	items := make([]DownloadQueueItem, len(q.items))
+	copy(items, q.items)
+	return items
+}
+
+func queueKey(url string, mediaType string, track int) string {
+	return fmt.Sprintf("%s|%s|%d", url, mediaType, track)
+}
+
+func (q *DownloadQueue) find(url string, mediaType string, track int) int {
+	return slices.IndexFunc(q.items, func(item DownloadQueueItem) bool {
+		return item.URL == url && item.MediaType == mediaType && item.Track == track
+	})
+}
+
+func (q *DownloadQueue) add(url string, mediaType string, track int) int {
+	q.items = append(q.items, DownloadQueueItem{
+		URL:       url,
+		MediaType: mediaType,
+		Track:     track,
+		Priority:  len(q.items),
+	})
+	return len(q.items) - 1
+}
+
+// renumber makes the priorities follow the queue order
+func (q *DownloadQueue) renumber() {
+	for i := range q.items {
+		q.items[i].Priority = i
+	}
+}
+
+// Start restarts the entries that were downloading when the app quit and
+// follows the download progress
+func (q *DownloadQueue) Start() {
+	events.Subscribe(func(topic string, payload any) {
+		// The bus waits for this callback, which must neither block nor emit,
+		// so it only wakes the queue's own goroutine
+		if status, ok := payload.(*remote.DownloadStatus); ok && topic == "download:progress" {
+			switch status.Status {
+			case "COMPLETED", "ERROR", "STOPPED":
+				q.wakeUp()
+			}
+		}
+	})
+	go q.run()
+
+	q.update(func() bool {
+		changed := false
+		for i := range q.items {
+			if q.items[i].Status == QueueStatusDownloading {
+				q.items[i].Status = QueueStatusQueued
+				changed = true
+			}
+		}
+		return changed
+	})
+	q.schedule()
+}
+
+func (q *DownloadQueue) wakeUp() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run schedules the queue when a download ends, and every queueRecheck in
+// case the end was missed
+func (q *DownloadQueue) run() {
+	ticker := time.NewTicker(queueRecheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		}
+		q.schedule()
+	}
+}
+
+// reconcile records the entries whose track stopped downloading. Progress
+// events only wake the queue; the status of the track is what counts.
+func (q *DownloadQueue) reconcile() {
+	q.mu.Lock()
+	var running []DownloadQueueItem
+	for _, item := range q.items {
+		if item.Status == QueueStatusDownloading && !q.starting[queueKey(item.URL, item.MediaType, item.Track)] {
+			running = append(running, item)
+		}
+	}
+	q.mu.Unlock()
+
+	statuses := make(map[string]*remote.DownloadStatusQeuryResponse)
+	for _, item := range running {
+		if status, loaded := q.downloader.TrackStatus(item.URL, item.MediaType, item.Track); loaded {
+			statuses[queueKey(item.URL, item.MediaType, item.Track)] = status
+		}
+	}
+
+	err := q.update(func() bool {
+		changed := false
+		for i := range q.items {
+			key := queueKey(q.items[i].URL, q.items[i].MediaType, q.items[i].Track)
+			status := statuses[key]
+			if status == nil || q.items[i].Status != QueueStatusDownloading || q.starting[key] {
+				continue
+			}
+			switch status.Status {
+			case "COMPLETED":
+				q.items[i].Status = QueueStatusCompleted
+			case "ERROR":
+				q.items[i].Status = QueueStatusError
+				q.items[i].Error = fmt.Sprintf("%d segments failed", len(status.Failed))
+			case "STOPPED", "IDLE":
+				q.items[i].Status = QueueStatusPaused
+			default:
+				continue
+			}
+			changed = true
+		}
+		return changed
+	})
+	if err != nil {
+		logger.Warn("Failed to save download queue", "error", err)
+	}
+}
+
+// schedule starts queued entries in priority order until
+// maxQueuedDownloads are downloading
+func (q *DownloadQueue) schedule() {
+	q.reconcile()
+
+	var start []DownloadQueueItem
+	err := q.update(func() bool {
+		active := 0
+		for _, item := range q.items {
+			if item.Status == QueueStatusDownloading {
+				active++
+			}
+		}
+		for i := range q.items {
+			if active >= maxQueuedDownloads {
+				break
+			}
+			if q.items[i].Status == QueueStatusQueued {
+				q.items[i].Status = QueueStatusDownloading
+				q.items[i].Error = ""
+				start = append(start, q.items[i])
+				active++
+			}
+		}
+		return len(start) > 0
+	})
+	if err != nil {
+		logger.Warn("Failed to save download queue", "error", err)
+	}
+
+	// Starting may extract the media again, which takes a while
+	for _, item := range start {
+		go q.startEntry(item.URL, item.MediaType, item.Track, func() error {
+			return q.downloader.StartDownload(item.URL, item.MediaType, item.Track)
+		})
+	}
+}
+
+// startEntry runs start for a downloading entry. Until it returns the track
+// may not report the new download yet, so reconcile skips the entry.
+func (q *DownloadQueue) startEntry(url string, mediaType string, track int, start func() error) error {
+	key := queueKey(url, mediaType, track)
+	q.mu.Lock()
+	q.starting[key] = true
+	q.mu.Unlock()
+
+	err := start()
+
+	q.mu.Lock()
+	delete(q.starting, key)
+	q.mu.Unlock()
+	if err != nil {
+		logger.Warn("Failed to start download", "url", url, "error", err)
+		q.failed(url, mediaType, track, err)
+	}
+	q.schedule()
+	return err
+}
+
+// failed records that an entry could not be started
+func (q *DownloadQueue) failed(url string, mediaType string, track int, cause error) {
+	err := q.update(func() bool {
+		index := q.find(url, mediaType, track)
+		if index < 0 {
+			return false
+		}
+		q.items[index].Status = QueueStatusError
+		q.items[index].Error = cause.Error()
+		return true
+	})
+	if err != nil {
+		logger.Warn("Failed to save download queue", "error", err)
+	}
+}
+
+// Add appends a track to the queue. A track already in the queue is queued
+// again unless it is downloading.
+func (q *DownloadQueue) Add(url string, mediaType string, track int) error {
+	err := q.update(func() bool {
+		index := q.find(url, mediaType, track)
+		if index < 0 {
+			index = q.add(url, mediaType, track)
+		} else if q.items[index].Status == QueueStatusDownloading {
+			return false
+		}
+		q.items[index].Status = QueueStatusQueued
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	q.schedule()
+	return nil
+}
+
+// StartDirect runs start for a track started directly, ahead of the queue
+// order, and follows it like the queued ones
+func (q *DownloadQueue) StartDirect(url string, mediaType string, track int, start func() error) error {
+	err := q.update(func() bool {
+		index := q.find(url, mediaType, track)
+		if index < 0 {
+			index = q.add(url, mediaType, track)
+		}
+		q.items[index].Status = QueueStatusDownloading
+		q.items[index].Error = ""
+		return true
+	})
+	if err != nil {
+		logger.Warn("Failed to save download queue", "error", err)
+	}
+	return q.startEntry(url, mediaType, track, start)
+}
+
+// Pause stops an entry and keeps it in the queue until it is resumed
+func (q *DownloadQueue) Pause(url string, mediaType string, track int) error {
+	found, downloading := false, false
+	err := q.update(func() bool {
+		index := q.find(url, mediaType, track)
+		if index < 0 {
+			return false
+		}
+		found = true
+		downloading = q.items[index].Status == QueueStatusDownloading
+		q.items[index].Status = QueueStatusPaused
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("download not queued: %s %s %d", url, mediaType, track)
+	}
+
+	if downloading {
+		if err := q.downloader.StopDownload(url, mediaType, track); err != nil {
+			return err
+		}
+		q.schedule()
+	}
+	return nil
+}
+
+// Resume queues a paused or failed entry again
+func (q *DownloadQueue) Resume(url string, mediaType string, track int) error {
+	found := false
+	err := q.update(func() bool {
+		index := q.find(url, mediaType, track)
+		if index < 0 {
+			return false
+		}
+		found = true
+		if q.items[index].Status == QueueStatusDownloading {
+			return false
+		}
+		q.items[index].Status = QueueStatusQueued
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("download not queued: %s %s %d", url, mediaType, track)
+	}
+	q.schedule()
+	return nil
+}
+
+// Move puts an entry at position in the queue. Running downloads are not
+// interrupted by entries moved before them.
+func (q *DownloadQueue) Move(url string, mediaType string, track int, position int) error {
+	found := false
+	err := q.update(func() bool {
+		index := q.find(url, mediaType, track)
+		if index < 0 {
+			return false
+		}
+		found = true
+		item := q.items[index]
+		q.items = slices.Delete(q.items, index, index+1)
+		position = max(0, min(position, len(q.items)))
+		q.items = slices.Insert(q.items, position, item)
+		q.renumber()
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("download not queued: %s %s %d", url, mediaType, track)
+	}
+	return nil
+}
+
+// Remove stops an entry and drops it from the queue. Downloaded segments are
+// kept.
+func (q *DownloadQueue) Remove(url string, mediaType string, track int) error {
+	downloading := false
+	err := q.update(func() bool {
+		index := q.find(url, mediaType, track)
+		if index < 0 {
+			return false
+		}
+		downloading = q.items[index].Status == QueueStatusDownloading
+		q.items = slices.Delete(q.items, index, index+1)
+		q.renumber()
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if downloading {
+		if err := q.downloader.StopDownload(url, mediaType, track); err != nil {
+			return err
+		}
+		q.schedule()
+	}
+	return nil
+}
+
+// Clear empties the queue, after the downloads were stopped and removed
+func (q *DownloadQueue) Clear() error {
+	return q.update(func() bool {
+		q.items = []DownloadQueueItem{}
+		return true
+	})
+}
+
+func (q *DownloadQueue) GetAll() []DownloadQueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.snapshot()
+}
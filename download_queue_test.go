@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"wails-cast/pkg/remote"
+)
+
+// fakeDownloader reports the configured track statuses and records the
+// downloads started
+type fakeDownloader struct {
+	mu       sync.Mutex
+	statuses map[int]*remote.DownloadStatusQeuryResponse
+	failures map[int]error
+	started  []int
+	stopped  []int
+}
+
+func (f *fakeDownloader) StartDownload(url string, mediaType string, index int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures[index]; err != nil {
+		return err
+	}
+	f.started = append(f.started, index)
+	return nil
+}
+
+func (f *fakeDownloader) StopDownload(url string, mediaType string, index int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = append(f.stopped, index)
+	return nil
+}
+
+func (f *fakeDownloader) TrackStatus(url string, mediaType string, index int) (*remote.DownloadStatusQeuryResponse, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status, found := f.statuses[index]
+	return status, found
+}
+
+func (f *fakeDownloader) startedTracks() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	started := slices.Clone(f.started)
+	slices.Sort(started)
+	return started
+}
+
+// newTestQueue returns a queue of the tracks 0..len(statuses)-1 of one
+// media, persisted in a temporary folder
+func newTestQueue(t *testing.T, downloader trackDownloader, statuses ...string) *DownloadQueue {
+	t.Helper()
+	queue := &DownloadQueue{
+		items:      []DownloadQueueItem{},
+		filePath:   filepath.Join(t.TempDir(), downloadQueueFileName),
+		downloader: downloader,
+		starting:   make(map[string]bool),
+		wake:       make(chan struct{}, 1),
+	}
+	for i, status := range statuses {
+		queue.items = append(queue.items, DownloadQueueItem{URL: "https://example.com/show", MediaType: "video", Track: i, Priority: i, Status: status})
+	}
+	return queue
+}
+
+func queueStatuses(queue *DownloadQueue) []string {
+	var statuses []string
+	for _, item := range queue.GetAll() {
+		statuses = append(statuses, item.Status)
+	}
+	return statuses
+}
+
+// waitForQueue waits for the downloads started in the background to settle
+// on the statuses want and the started tracks
+func waitForQueue(t *testing.T, queue *DownloadQueue, downloader *fakeDownloader, want []string, started []int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !slices.Equal(queueStatuses(queue), want) || !slices.Equal(downloader.startedTracks(), started) {
+		if time.Now().After(deadline) {
+			t.Fatalf("statuses = %v, want %v; started = %v, want %v",
+				queueStatuses(queue), want, downloader.startedTracks(), started)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDownloadQueueSchedule(t *testing.T) {
+	const (
+		queued      = QueueStatusQueued
+		downloading = QueueStatusDownloading
+		paused      = QueueStatusPaused
+		completed   = QueueStatusCompleted
+		failed      = QueueStatusError
+	)
+	tests := []struct {
+		name     string
+		items    []string
+		tracks   map[int]*remote.DownloadStatusQeuryResponse
+		failures map[int]error
+		want     []string
+		started  []int
+		errors   map[int]string
+	}{
+		{
+			name:    "starts in priority order",
+			items:   []string{queued, queued, queued},
+			want:    []string{downloading, downloading, queued},
+			started: []int{0, 1},
+		},
+		{
+			name:  "skips paused and finished entries",
+			items: []string{completed, paused, failed, queued, queued, queued},
+			want:  []string{completed, paused, failed, downloading, downloading, queued},
+			// Entries paused or failed are only restarted by Resume
+			started: []int{3, 4},
+		},
+		{
+			name:    "running downloads keep their slot",
+			items:   []string{downloading, queued, downloading, queued},
+			tracks:  map[int]*remote.DownloadStatusQeuryResponse{0: {Status: "INPROGRESS"}, 2: {Status: "SCHEDULED"}},
+			want:    []string{downloading, queued, downloading, queued},
+			started: nil,
+		},
+		{
+			name:    "completed track frees its slot",
+			items:   []string{downloading, downloading, queued},
+			tracks:  map[int]*remote.DownloadStatusQeuryResponse{0: {Status: "COMPLETED"}, 1: {Status: "INPROGRESS"}},
+			want:    []string{completed, downloading, downloading},
+			started: []int{2},
+		},
+		{
+			name:    "failed segments fail the entry",
+			items:   []string{downloading, queued},
+			tracks:  map[int]*remote.DownloadStatusQeuryResponse{0: {Status: "ERROR", Failed: []int{3, 8}}},
+			want:    []string{failed, downloading},
+			started: []int{1},
+			errors:  map[int]string{0: "2 segments failed"},
+		},
+		{
+			name:    "stopped track is paused",
+			items:   []string{downloading, queued},
+			tracks:  map[int]*remote.DownloadStatusQeuryResponse{0: {Status: "STOPPED"}},
+			want:    []string{paused, downloading},
+			started: []int{1},
+		},
+		{
+			name:    "track that is not loaded keeps downloading",
+			items:   []string{downloading, downloading, queued},
+			want:    []string{downloading, downloading, queued},
+			started: nil,
+		},
+		{
+			name:     "failing start moves on to the next entry",
+			items:    []string{queued, queued, queued},
+			failures: map[int]error{0: errors.New("extraction failed")},
+			want:     []string{failed, downloading, downloading},
+			started:  []int{1, 2},
+			errors:   map[int]string{0: "extraction failed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader := &fakeDownloader{statuses: tt.tracks, failures: tt.failures}
+			queue := newTestQueue(t, downloader, tt.items...)
+
+			queue.schedule()
+			waitForQueue(t, queue, downloader, tt.want, tt.started)
+
+			for _, item := range queue.GetAll() {
+				if item.Error != tt.errors[item.Track] {
+					t.Errorf("track %d error = %q, want %q", item.Track, item.Error, tt.errors[item.Track])
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadQueueMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		track    int
+		position int
+		want     []int
+		wantErr  bool
+	}{
+		{name: "to the front", track: 2, position: 0, want: []int{2, 0, 1, 3}},
+		{name: "to the back", track: 0, position: 3, want: []int{1, 2, 3, 0}},
+		{name: "past the end", track: 1, position: 10, want: []int{0, 2, 3, 1}},
+		{name: "before the start", track: 3, position: -1, want: []int{3, 0, 1, 2}},
+		{name: "same position", track: 1, position: 1, want: []int{0, 1, 2, 3}},
+		{name: "not queued", track: 7, position: 0, want: []int{0, 1, 2, 3}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newTestQueue(t, &fakeDownloader{}, QueueStatusPaused, QueueStatusPaused, QueueStatusPaused, QueueStatusPaused)
+
+			err := queue.Move("https://example.com/show", "video", tt.track, tt.position)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var tracks []int
+			for i, item := range queue.GetAll() {
+				tracks = append(tracks, item.Track)
+				if item.Priority != i {
+					t.Errorf("track %d priority = %d, want %d", item.Track, item.Priority, i)
+				}
+			}
+			if !slices.Equal(tracks, tt.want) {
+				t.Errorf("order = %v, want %v", tracks, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadQueuePersistence(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	downloader := &fakeDownloader{}
+	queue := NewDownloadQueue(downloader)
+	for track := range 3 {
+		if err := queue.Add("https://example.com/show", "video", track); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Move("https://example.com/show", "video", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitForQueue(t, queue, downloader, []string{QueueStatusQueued, QueueStatusDownloading, QueueStatusDownloading}, []int{0, 1})
+
+	// The file holds the queue as the frontend lists it
+	data, err := os.ReadFile(queue.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []DownloadQueueItem
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(saved, queue.GetAll()) {
+		t.Errorf("saved %+v, want %+v", saved, queue.GetAll())
+	}
+
+	// A restart loads the queue and starts its downloads again
+	restarted := &fakeDownloader{}
+	reloaded := NewDownloadQueue(restarted)
+	if !slices.Equal(reloaded.GetAll(), saved) {
+		t.Errorf("reloaded %+v, want %+v", reloaded.GetAll(), saved)
+	}
+	reloaded.Start()
+	waitForQueue(t, reloaded, restarted, []string{QueueStatusDownloading, QueueStatusDownloading, QueueStatusQueued}, []int{0, 2})
+}
@@ -0,0 +1,15 @@
+export const aspectRatioOptions = [
+  { value: "", label: "Source" },
+  { value: "16:9", label: "16:9" },
+  { value: "4:3", label: "4:3" },
+  { value: "21:9", label: "21:9" },
+  { value: "1:1", label: "1:1" },
+];
+
+export const zoomOptions = [
+  { value: 0, label: "None" },
+  { value: 1.1, label: "110%" },
+  { value: 1.25, label: "125%" },
+  { value: 1.33, label: "133% (4:3 windowbox)" },
+  { value: 1.5, label: "150%" },
+];
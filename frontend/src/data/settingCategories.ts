@@ -41,6 +41,12 @@ export const settingCategories: SettingCategory[] = [
         description: "Render subtitles in italic",
         type: "boolean",
       },
+      {
+        key: "subtitleForceStyle",
+        label: "Override ASS/SSA Styles",
+        description: "Apply the font size and style above to burned-in ASS/SSA subtitles instead of their own styling",
+        type: "boolean",
+      },
     ],
   },
   {
@@ -135,6 +141,96 @@ export const settingCategories: SettingCategory[] = [
         max: 3840,
         step: 1,
       },
+      {
+        key: "videoEncoder",
+        label: "Video Encoder",
+        description: "H.264 encoder used for transcoding. Auto picks the first working hardware encoder and falls back to libx264.",
+        type: "select",
+        options: [
+          { value: "auto", label: "Auto" },
+          { value: "libx264", label: "Software (libx264)" },
+          { value: "h264_videotoolbox", label: "Apple VideoToolbox" },
+          { value: "h264_nvenc", label: "NVIDIA NVENC" },
+          { value: "h264_qsv", label: "Intel Quick Sync" },
+          { value: "h264_vaapi", label: "VA-API" },
+        ],
+      },
+      {
+        key: "directPlay",
+        label: "Direct Play",
+        description: "Stream-copy media the Chromecast can already decode instead of re-encoding it",
+        type: "boolean",
+      },
+      {
+        key: "segmentContainer",
+        label: "Segment Container",
+        description: "Fragmented MP4 (CMAF) is needed by some receivers for HEVC and HDR streams",
+        type: "select",
+        options: [
+          { value: "mpegts", label: "MPEG-TS" },
+          { value: "fmp4", label: "Fragmented MP4 (CMAF)" },
+        ],
+      },
+      {
+        key: "adaptiveBitrate",
+        label: "Adaptive Bitrate",
+        description: "Offer 5M, 3M and 1.5M renditions so the Chromecast can step down on a weak Wi-Fi link",
+        type: "boolean",
+      },
+      {
+        key: "deinterlace",
+        label: "Deinterlacing",
+        description: "Filter applied to local files detected as interlaced, such as DVD rips and TV captures. Files are scanned once before their first cast",
+        type: "select",
+        options: [
+          { value: "bwdif", label: "Bob Weaver (bwdif)" },
+          { value: "yadif", label: "Yadif (faster)" },
+          { value: "off", label: "Off" },
+        ],
+      },
+      {
+        key: "autoCrop",
+        label: "Crop Black Bars",
+        description: "Detect letterboxing in local files and crop it away. Files are scanned once before their first cast",
+        type: "boolean",
+      },
+    ],
+  },
+  {
+    id: "audio",
+    label: "Audio",
+    icon: "Volume2",
+    settings: [
+      {
+        key: "audioLoudnorm",
+        label: "Loudness Normalization",
+        description: "Normalize loudness to the EBU R128 standard so every title plays at a similar volume",
+        type: "boolean",
+      },
+      {
+        key: "audioNightMode",
+        label: "Night Mode",
+        description: "Compress the dynamic range so loud action scenes don't drown out quiet dialogue",
+        type: "boolean",
+      },
+      {
+        key: "audioDialogueBoost",
+        label: "Dialogue Boost",
+        description: "Raise the center (dialogue) channel when downmixing surround sound to stereo",
+        type: "boolean",
+      },
+      {
+        key: "audioBitrate",
+        label: "Audio Bitrate",
+        description: "AAC bitrate for transcoded audio",
+        type: "select",
+        options: [
+          { value: "96k", label: "96 kbps" },
+          { value: "128k", label: "128 kbps" },
+          { value: "192k", label: "192 kbps" },
+          { value: "256k", label: "256 kbps" },
+        ],
+      },
     ],
   },
   {
@@ -148,6 +244,63 @@ export const settingCategories: SettingCategory[] = [
         description: "Disable caching of transcoded video segments",
         type: "boolean",
       },
+      {
+        key: "prefetchSegments",
+        label: "Prefetch Segments",
+        description: "Segments transcoded ahead of the playhead in the background (0 to disable, requires the transcoding cache)",
+        type: "number",
+        min: 0,
+        max: 20,
+        step: 1,
+      },
+      {
+        key: "prefetchWorkers",
+        label: "Prefetch Workers",
+        description: "Number of segments prefetched in parallel",
+        type: "number",
+        min: 1,
+        max: 8,
+        step: 1,
+      },
+      {
+        key: "maxFfmpegProcesses",
+        label: "Max FFmpeg Processes",
+        description: "Maximum number of simultaneous ffmpeg transcodes (0 for unlimited)",
+        type: "number",
+        min: 0,
+        max: 16,
+        step: 1,
+      },
+      {
+        key: "downloadParallelism",
+        label: "Download Parallelism",
+        description: "Number of segments of a remote stream downloaded in parallel",
+        type: "number",
+        min: 1,
+        max: 16,
+        step: 1,
+      },
+      {
+        key: "downloadRateLimit",
+        label: "Download Rate Limit",
+        description: "Maximum speed of background downloads in KB/s (0 for unlimited)",
+        type: "number",
+        min: 0,
+        max: 1000000,
+        step: 100,
+      },
+      {
+        key: "downloadWindowStart",
+        label: "Download Window Start",
+        description: "Background downloads pause outside the download window (leave empty to download at any time)",
+        type: "time",
+      },
+      {
+        key: "downloadWindowEnd",
+        label: "Download Window End",
+        description: "End of the download window; a window ending before it starts spans midnight",
+        type: "time",
+      },
     ],
   },
   {
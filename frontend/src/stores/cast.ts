@@ -13,6 +13,8 @@ interface FrontendCastOptions {
   VideoTrack: number;
   AudioTrack: number;
   Bitrate: string;
+  AspectRatio: string;
+  Zoom: number;
   SubtitleType: string;
   SubtitlePath: string;
 }
@@ -114,6 +116,8 @@ export const useCastStore = defineStore("cast", () => {
         VideoTrack: historyCastOptions.VideoTrack,
         AudioTrack: historyCastOptions.AudioTrack,
         Bitrate: historyCastOptions.Bitrate,
+        AspectRatio: historyCastOptions.AspectRatio ?? "",
+        Zoom: historyCastOptions.Zoom ?? 0,
         SubtitleType: subtitleItem.type,
         SubtitlePath: subtitleItem.path,
       };
@@ -122,6 +126,8 @@ export const useCastStore = defineStore("cast", () => {
         VideoTrack: 0,
         AudioTrack: 0,
         Bitrate: settingsStore.settings.defaultQuality,
+        AspectRatio: "",
+        Zoom: 0,
         SubtitleType: info?.NearSubtitle ? "external" : "none",
         SubtitlePath: info?.NearSubtitle || "",
       };
@@ -193,6 +199,8 @@ export const useCastStore = defineStore("cast", () => {
           audioTrack: castOptions.value.AudioTrack,
           subtitlePath,
           quality: castOptions.value.Bitrate,
+          aspectRatio: castOptions.value.AspectRatio,
+          zoom: castOptions.value.Zoom,
         }
       );
       startStatePoll();
@@ -202,6 +210,8 @@ export const useCastStore = defineStore("cast", () => {
         VideoTrack: castOptions.value.VideoTrack,
         AudioTrack: castOptions.value.AudioTrack,
         Bitrate: castOptions.value.Bitrate,
+        AspectRatio: castOptions.value.AspectRatio,
+        Zoom: castOptions.value.Zoom,
         SubtitlePath: subtitlePath,
       };
       playbackState.value = await mediaService.castToDevice(
@@ -280,6 +290,7 @@ export const useCastStore = defineStore("cast", () => {
       DelaySeconds: overrides.delaySeconds ?? getSubtitleDelay(mediaPath),
       Bold: overrides.bold ?? settings?.subtitleBold ?? false,
       Italic: overrides.italic ?? settings?.subtitleItalic ?? false,
+      ForceStyle: settings?.subtitleForceStyle ?? false,
     };
 
     await mediaService.updateSubtitleSettings(opts);
@@ -2,22 +2,62 @@ import { defineStore } from "pinia";
 import { ref } from "vue";
 import { EventsOn } from "../../wailsjs/runtime/runtime";
 import {
+  EnqueueDownload,
+  GetDownloadQueue,
   GetDownloadStatus,
+  MoveQueuedDownload,
+  PauseQueuedDownload,
+  RemoveQueuedDownload,
+  ResumeQueuedDownload,
+  RetryFailedDownloads,
+  SetDownloadRateLimit,
   StartDownload,
   StopDownload,
 } from "../../wailsjs/go/main/App";
-import { remote } from "wailsjs/go/models";
+import { main, remote } from "wailsjs/go/models";
 
 export const useDownloadsStore = defineStore("downloads", () => {
   // State: Map with key as "url|videoTrack|audioTrack"
   const downloads = ref<Record<string, remote.DownloadStatus>>({});
 
+  // Persisted queue in priority order
+  const queue = ref<main.DownloadQueueItem[]>([]);
+
   // Listen to download progress events
   EventsOn("download:progress", (data: remote.DownloadStatus) => {
     const key = `${data.URL}|${data.MediaType}|${data.Track}`;
     downloads.value[key] = data;
   });
 
+  EventsOn("download-queue:updated", (items: main.DownloadQueueItem[]) => {
+    queue.value = items;
+  });
+
+  const loadQueue = async () => {
+    queue.value = await GetDownloadQueue();
+  };
+
+  const enqueue = async (url: string, mediaType: string, track: number) => {
+    await EnqueueDownload(url, mediaType, track);
+  };
+
+  // Moves a queue entry to position (0 = next to download)
+  const moveQueued = async (item: main.DownloadQueueItem, position: number) => {
+    await MoveQueuedDownload(item.url, item.mediaType, item.track, position);
+  };
+
+  const pauseQueued = async (item: main.DownloadQueueItem) => {
+    await PauseQueuedDownload(item.url, item.mediaType, item.track);
+  };
+
+  const resumeQueued = async (item: main.DownloadQueueItem) => {
+    await ResumeQueuedDownload(item.url, item.mediaType, item.track);
+  };
+
+  const removeQueued = async (item: main.DownloadQueueItem) => {
+    await RemoveQueuedDownload(item.url, item.mediaType, item.track);
+  };
+
   const startDownload = async (
     url: string,
     mediaType: string,
@@ -33,6 +73,19 @@ export const useDownloadsStore = defineStore("downloads", () => {
   ) => {
     await StopDownload(url, mediaType, track);
   };
+
+  const retryFailed = async (
+    url: string,
+    mediaType: string,
+    track: number
+  ) => {
+    await RetryFailedDownloads(url, mediaType, track);
+  };
+
+  // Caps the background downloads of a media in KB/s (0 = global limit only)
+  const setRateLimit = async (url: string, kbps: number) => {
+    await SetDownloadRateLimit(url, kbps);
+  };
   // Actions
   const getDownloadState = (url: string, mediaType: string, track: number) => {
     const key = `${url}|${mediaType}|${track}`;
@@ -51,14 +104,25 @@ export const useDownloadsStore = defineStore("downloads", () => {
       Track: track,
       Status: status.Status,
       Segments: status.Segments,
+      Failed: status.Failed,
+      RateLimit: downloads.value[`${url}|${mediaType}|${track}`]?.RateLimit ?? 0,
     };
   };
 
   return {
     downloads,
+    queue,
+    loadQueue,
+    enqueue,
+    moveQueued,
+    pauseQueued,
+    resumeQueued,
+    removeQueued,
     getDownloadState,
     loadTrackProgress,
     startDownload,
     stopDownload,
+    retryFailed,
+    setRateLimit,
   };
 });
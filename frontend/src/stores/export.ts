@@ -0,0 +1,82 @@
+import { defineStore } from "pinia";
+import { ref } from "vue";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
+import {
+  CancelExport,
+  ExportRemoteMedia,
+  OpenExportFolderDialog,
+} from "../../wailsjs/go/main/App";
+import { options } from "wailsjs/go/models";
+import { useToast } from "vue-toastification";
+
+export const useExportStore = defineStore("export", () => {
+  const isExporting = ref(false);
+  const isCancelling = ref(false);
+  // URL of the media being exported; only one export runs at a time
+  const activeUrl = ref<string | null>(null);
+  const progress = ref(0);
+
+  const toast = useToast();
+
+  const finish = () => {
+    isExporting.value = false;
+    isCancelling.value = false;
+    activeUrl.value = null;
+  };
+
+  EventsOn("export:progress", (data: { URL: string; Progress: number }) => {
+    progress.value = data.Progress;
+  });
+
+  EventsOn("export:complete", (path: string) => {
+    finish();
+    toast.success(`Exported to ${path}`);
+  });
+
+  EventsOn("export:error", (error: string) => {
+    finish();
+    toast.error(`Export failed: ${error}`);
+  });
+
+  EventsOn("export:cancelled", () => {
+    finish();
+    toast.info("Export cancelled");
+  });
+
+  // Asks for a folder and exports the downloaded tracks of a remote media
+  // into it as a single file named after the title
+  async function start(
+    url: string,
+    castOptions: options.CastOptions,
+    format: "mp4" | "mkv"
+  ) {
+    const folder = await OpenExportFolderDialog();
+    if (!folder) return;
+
+    progress.value = 0;
+    isExporting.value = true;
+    isCancelling.value = false;
+    activeUrl.value = url;
+    try {
+      await ExportRemoteMedia(url, castOptions, folder, format);
+    } catch (e) {
+      finish();
+      throw e;
+    }
+  }
+
+  async function cancel() {
+    if (!isExporting.value) return;
+    isCancelling.value = true;
+    await CancelExport();
+  }
+
+  return {
+    isExporting,
+    isCancelling,
+    activeUrl,
+    progress,
+    start,
+    cancel,
+  };
+});
@@ -12,7 +12,7 @@ export interface SettingDefinition {
   key: keyof main.Settings;
   label: string;
   description: string;
-  type: "boolean" | "text" | "password" | "number" | "select" | "dynamic-select" | "textarea";
+  type: "boolean" | "text" | "password" | "number" | "select" | "dynamic-select" | "textarea" | "time";
   min?: number;
   max?: number;
   step?: number;
@@ -48,6 +48,27 @@ export const useSettingsStore = defineStore("settings", () => {
     settings.value = newSettings;
   };
 
+  // Save the audio mode (stereo, surround-aac, surround-ac3, passthrough)
+  // used when casting to a device, keyed by its UUID (its host without one)
+  const setDeviceAudioMode = async (deviceKey: string, mode: string) => {
+    await saveSettings({
+      ...settings.value,
+      deviceAudioModes: {
+        ...settings.value.deviceAudioModes,
+        [deviceKey]: mode,
+      },
+    });
+  };
+
+  // Pass HDR video through to a device instead of tone-mapping it to SDR,
+  // keyed like the audio modes
+  const setDeviceKeepHDR = async (deviceKey: string, keep: boolean) => {
+    await saveSettings({
+      ...settings.value,
+      deviceKeepHdr: { ...settings.value.deviceKeepHdr, [deviceKey]: keep },
+    });
+  };
+
   // Reset to defaults
   const resetToDefaults = async () => {
     settings.value = await ResetSettings();
@@ -91,5 +112,7 @@ export const useSettingsStore = defineStore("settings", () => {
     resetToDefaults,
     loadSettings,
     saveSettings,
+    setDeviceAudioMode,
+    setDeviceKeepHDR,
   };
 });
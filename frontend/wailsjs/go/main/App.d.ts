@@ -8,6 +8,8 @@ import {ffmpeg} from '../models';
 
 export function ApplyRemoteAPISettings(arg1:boolean,arg2:number,arg3:string):Promise<void>;
 
+export function CancelExport():Promise<void>;
+
 export function CancelSeasonTranslation():Promise<void>;
 
 export function CancelTranslation():Promise<void>;
@@ -26,14 +28,20 @@ export function DiscoverCastInstances():Promise<Array<main.CastInstance>>;
 
 export function DiscoverDevices():Promise<Array<main.Device>>;
 
+export function EnqueueDownload(arg1:string,arg2:string,arg3:number):Promise<void>;
+
 export function Export():Promise<main.AppExports>;
 
 export function ExportEmbeddedSubtitles(arg1:string):Promise<void>;
 
+export function ExportRemoteMedia(arg1:string,arg2:options.CastOptions,arg3:string,arg4:string):Promise<void>;
+
 export function GenerateTranslationPrompt(arg1:string,arg2:string):Promise<string>;
 
 export function GetCacheStats():Promise<folders.CacheStats>;
 
+export function GetDownloadQueue():Promise<Array<main.DownloadQueueItem>>;
+
 export function GetDownloadStatus(arg1:string,arg2:string,arg3:number):Promise<remote.DownloadStatusQeuryResponse>;
 
 export function GetFFmpegInfo(arg1:boolean):Promise<ffmpeg.FFmpegInfo>;
@@ -60,6 +68,10 @@ export function LogInfo(arg1:string,arg2:Array<any>):Promise<void>;
 
 export function LogWarn(arg1:string,arg2:Array<any>):Promise<void>;
 
+export function MoveQueuedDownload(arg1:string,arg2:string,arg3:number,arg4:number):Promise<void>;
+
+export function OpenExportFolderDialog():Promise<string>;
+
 export function OpenFileDialog(arg1:string,arg2:Array<string>):Promise<string>;
 
 export function OpenLibraryFolderDialog():Promise<string>;
@@ -70,6 +82,8 @@ export function OrganizeLibrary(arg1:Array<main.OrganizeMove>):Promise<void>;
 
 export function Pause():Promise<void>;
 
+export function PauseQueuedDownload(arg1:string,arg2:string,arg3:number):Promise<void>;
+
 export function PreviewOrganize(arg1:main.LibraryScanResult):Promise<Array<main.OrganizeMove>>;
 
 export function ProcessPastedTranslation(arg1:string,arg2:string,arg3:string):Promise<Array<string>>;
@@ -114,12 +128,20 @@ export function RemoteUpdateSubtitle(arg1:string,arg2:string,arg3:options.Subtit
 
 export function RemoveFromHistory(arg1:string):Promise<void>;
 
+export function RemoveQueuedDownload(arg1:string,arg2:string,arg3:number):Promise<void>;
+
 export function ResetSettings():Promise<main.Settings>;
 
+export function ResumeQueuedDownload(arg1:string,arg2:string,arg3:number):Promise<void>;
+
+export function RetryFailedDownloads(arg1:string,arg2:string,arg3:number):Promise<void>;
+
 export function ScanLibrary(arg1:string):Promise<main.LibraryScanResult>;
 
 export function SeekTo(arg1:number):Promise<void>;
 
+export function SetDownloadRateLimit(arg1:string,arg2:number):Promise<void>;
+
 export function SetMuted(arg1:boolean):Promise<void>;
 
 export function SetSubtitleSize(arg1:number):Promise<void>;
@@ -6,6 +6,10 @@ export function ApplyRemoteAPISettings(arg1, arg2, arg3) {
   return window['go']['main']['App']['ApplyRemoteAPISettings'](arg1, arg2, arg3);
 }
 
+export function CancelExport() {
+  return window['go']['main']['App']['CancelExport']();
+}
+
 export function CancelSeasonTranslation() {
   return window['go']['main']['App']['CancelSeasonTranslation']();
 }
@@ -42,6 +46,10 @@ export function DiscoverDevices() {
   return window['go']['main']['App']['DiscoverDevices']();
 }
 
+export function EnqueueDownload(arg1, arg2, arg3) {
+  return window['go']['main']['App']['EnqueueDownload'](arg1, arg2, arg3);
+}
+
 export function Export() {
   return window['go']['main']['App']['Export']();
 }
@@ -50,6 +58,10 @@ export function ExportEmbeddedSubtitles(arg1) {
   return window['go']['main']['App']['ExportEmbeddedSubtitles'](arg1);
 }
 
+export function ExportRemoteMedia(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['ExportRemoteMedia'](arg1, arg2, arg3, arg4);
+}
+
 export function GenerateTranslationPrompt(arg1, arg2) {
   return window['go']['main']['App']['GenerateTranslationPrompt'](arg1, arg2);
 }
@@ -58,6 +70,10 @@ export function GetCacheStats() {
   return window['go']['main']['App']['GetCacheStats']();
 }
 
+export function GetDownloadQueue() {
+  return window['go']['main']['App']['GetDownloadQueue']();
+}
+
 export function GetDownloadStatus(arg1, arg2, arg3) {
   return window['go']['main']['App']['GetDownloadStatus'](arg1, arg2, arg3);
 }
@@ -110,6 +126,14 @@ export function LogWarn(arg1, arg2) {
   return window['go']['main']['App']['LogWarn'](arg1, arg2);
 }
 
+export function MoveQueuedDownload(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['MoveQueuedDownload'](arg1, arg2, arg3, arg4);
+}
+
+export function OpenExportFolderDialog() {
+  return window['go']['main']['App']['OpenExportFolderDialog']();
+}
+
 export function OpenFileDialog(arg1, arg2) {
   return window['go']['main']['App']['OpenFileDialog'](arg1, arg2);
 }
@@ -130,6 +154,10 @@ export function Pause() {
   return window['go']['main']['App']['Pause']();
 }
 
+export function PauseQueuedDownload(arg1, arg2, arg3) {
+  return window['go']['main']['App']['PauseQueuedDownload'](arg1, arg2, arg3);
+}
+
 export function PreviewOrganize(arg1) {
   return window['go']['main']['App']['PreviewOrganize'](arg1);
 }
@@ -218,10 +246,22 @@ export function RemoveFromHistory(arg1) {
   return window['go']['main']['App']['RemoveFromHistory'](arg1);
 }
 
+export function RemoveQueuedDownload(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RemoveQueuedDownload'](arg1, arg2, arg3);
+}
+
 export function ResetSettings() {
   return window['go']['main']['App']['ResetSettings']();
 }
 
+export function ResumeQueuedDownload(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ResumeQueuedDownload'](arg1, arg2, arg3);
+}
+
+export function RetryFailedDownloads(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RetryFailedDownloads'](arg1, arg2, arg3);
+}
+
 export function ScanLibrary(arg1) {
   return window['go']['main']['App']['ScanLibrary'](arg1);
 }
@@ -230,6 +270,10 @@ export function SeekTo(arg1) {
   return window['go']['main']['App']['SeekTo'](arg1);
 }
 
+export function SetDownloadRateLimit(arg1, arg2) {
+  return window['go']['main']['App']['SetDownloadRateLimit'](arg1, arg2);
+}
+
 export function SetMuted(arg1) {
   return window['go']['main']['App']['SetMuted'](arg1);
 }
@@ -7,6 +7,8 @@ export namespace ffmpeg {
 	    ffprobeVersion: string;
 	    ffmpegPath: string;
 	    ffprobePath: string;
+	    videoEncoder: string;
+	    availableEncoders: string[];
 	}
 
 }
@@ -46,6 +48,14 @@ export namespace main {
 	    port: number;
 	    uuid: string;
 	}
+	export interface DownloadQueueItem {
+	    url: string;
+	    mediaType: string;
+	    track: number;
+	    priority: number;
+	    status: string;
+	    error?: string;
+	}
 	export interface HistoryItem {
 	    path: string;
 	    name: string;
@@ -117,6 +127,8 @@ export namespace main {
 	    audioTrack: number;
 	    subtitlePath: string;
 	    quality?: string;
+	    aspectRatio?: string;
+	    zoom?: number;
 	}
 	export interface SeasonTranslateProgress {
 	    showName: string;
@@ -140,7 +152,27 @@ export namespace main {
 	    subtitleDelaySeconds: number;
 	    subtitleBold: boolean;
 	    subtitleItalic: boolean;
+	    subtitleForceStyle: boolean;
 	    maxOutputWidth: number;
+	    videoEncoder: string;
+	    directPlay: boolean;
+	    segmentContainer: string;
+	    adaptiveBitrate: boolean;
+	    deinterlace: string;
+	    autoCrop: boolean;
+	    prefetchSegments: number;
+	    prefetchWorkers: number;
+	    maxFfmpegProcesses: number;
+	    downloadParallelism: number;
+	    downloadRateLimit: number;
+	    downloadWindowStart: string;
+	    downloadWindowEnd: string;
+	    audioLoudnorm: boolean;
+	    audioNightMode: boolean;
+	    audioDialogueBoost: boolean;
+	    audioBitrate: string;
+	    deviceAudioModes: Record<string, string>;
+	    deviceKeepHdr: Record<string, boolean>;
 	    translatePromptTemplate: string;
 	    maxSubtitleSamples: number;
 	    noTranscodeCache: boolean;
@@ -157,6 +189,7 @@ export namespace main {
 	export interface SubtitleDisplayItem {
 	    Path: string;
 	    Label: string;
+	    BurnInOnly: boolean;
 	}
 	export interface TorrentStatus {
 	    hash: string;
@@ -198,6 +231,8 @@ export namespace options {
 	    VideoTrack: number;
 	    AudioTrack: number;
 	    Bitrate: string;
+	    AspectRatio: string;
+	    Zoom: number;
 	}
 	export interface SubtitleCastOptions {
 	    Path: string;
@@ -207,6 +242,7 @@ export namespace options {
 	    DelaySeconds: number;
 	    Bold: boolean;
 	    Italic: boolean;
+	    ForceStyle: boolean;
 	}
 
 }
@@ -216,13 +252,16 @@ export namespace remote {
 	export interface DownloadStatus {
 	    Status: string;
 	    Segments: boolean[];
+	    Failed: number[];
 	    URL: string;
 	    MediaType: string;
 	    Track: number;
+	    RateLimit: number;
 	}
 	export interface DownloadStatusQeuryResponse {
 	    Status: string;
 	    Segments: boolean[];
+	    Failed: number[];
 	}
 
 }
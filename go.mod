@@ -10,6 +10,7 @@ require (
 	github.com/vishen/go-chromecast v0.3.4
 	github.com/wailsapp/wails/v2 v2.11.0
 	github.com/yuin/gopher-lua v1.1.2
+	golang.org/x/sync v0.18.0
 )
 
 require (
@@ -79,7 +80,6 @@ require (
 	golang.org/x/image v0.24.0 // indirect
 	golang.org/x/mod v0.30.0 // indirect
 	golang.org/x/net v0.47.0 // indirect
-	golang.org/x/sync v0.18.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
 	golang.org/x/tools v0.39.0 // indirect
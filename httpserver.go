@@ -92,6 +92,8 @@ type playOptions struct {
 	AudioTrack   int     `json:"audioTrack"`
 	SubtitlePath string  `json:"subtitlePath"` // "" or "none" = no subtitle
 	Quality      *string `json:"quality"`      // nil = default setting; "" = Original
+	AspectRatio  string  `json:"aspectRatio"`  // "" = source aspect ratio, e.g. "4:3"
+	Zoom         float64 `json:"zoom"`         // 0 = no zoom, e.g. 1.33
 }
 
 // playRequest is the body for POST /play.
@@ -222,6 +224,11 @@ func (h *HTTPServer) Start(port int) error {
 	mux.HandleFunc("/settings", h.handleSettings)
 	mux.HandleFunc("/torrent/add", h.handleTorrentAdd)
 	mux.HandleFunc("/torrents", h.handleTorrents)
+	mux.HandleFunc("/downloads", h.handleDownloads)
+	mux.HandleFunc("/downloads/move", h.handleDownloadMove)
+	mux.HandleFunc("/downloads/pause", h.handleDownloadPause)
+	mux.HandleFunc("/downloads/resume", h.handleDownloadResume)
+	mux.HandleFunc("/downloads/remove", h.handleDownloadRemove)
 	mux.HandleFunc("/library/tree", h.handleLibraryTree)
 	mux.HandleFunc("/library/identify", h.handleLibraryIdentify)
 	mux.HandleFunc("/library/organize/preview", h.handleOrganizePreview)
@@ -486,6 +493,8 @@ func (h *HTTPServer) castOptions(p playOptions) *options.CastOptions {
 		VideoTrack:   p.VideoTrack,
 		AudioTrack:   p.AudioTrack,
 		Bitrate:      bitrate,
+		AspectRatio:  p.AspectRatio,
+		Zoom:         p.Zoom,
 	}
 }
 
@@ -847,6 +856,106 @@ func (h *HTTPServer) handleTorrents(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"items": torrents})
 }
 
+// ----------------------------------------------------------------------------
+// Download queue (remote stream downloads)
+// ----------------------------------------------------------------------------
+
+// downloadRequest is the body for POST /downloads and /downloads/*.
+type downloadRequest struct {
+	URL       string `json:"url"`
+	MediaType string `json:"mediaType"`
+	Track     int    `json:"track"`
+	Position  int    `json:"position"` // Only for /downloads/move
+}
+
+// decodeDownloadRequest reads a POST download request, writing the error
+// response when it is invalid.
+func decodeDownloadRequest(w http.ResponseWriter, r *http.Request) (*downloadRequest, bool) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return nil, false
+	}
+	var req downloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON body"})
+		return nil, false
+	}
+	if req.URL == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "url is required"})
+		return nil, false
+	}
+	if req.MediaType == "" {
+		req.MediaType = "video"
+	}
+	return &req, true
+}
+
+// handleDownloads lists the download queue (GET) or adds a track to it (POST).
+func (h *HTTPServer) handleDownloads(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]any{"items": h.app.GetDownloadQueue()})
+		return
+	}
+	req, ok := decodeDownloadRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.app.EnqueueDownload(req.URL, req.MediaType, req.Track); err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// handleDownloadMove moves a queued track to a position (0 = next).
+func (h *HTTPServer) handleDownloadMove(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDownloadRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.app.MoveQueuedDownload(req.URL, req.MediaType, req.Track, req.Position); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (h *HTTPServer) handleDownloadPause(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDownloadRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.app.PauseQueuedDownload(req.URL, req.MediaType, req.Track); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (h *HTTPServer) handleDownloadResume(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDownloadRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.app.ResumeQueuedDownload(req.URL, req.MediaType, req.Track); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (h *HTTPServer) handleDownloadRemove(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDownloadRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.app.RemoveQueuedDownload(req.URL, req.MediaType, req.Track); err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
 // ----------------------------------------------------------------------------
 // Library management (remote mode: scan tree / identify / organize / season-translate)
 // ----------------------------------------------------------------------------
@@ -175,6 +175,39 @@ func (c *Client) Torrents() ([]TorrentStatus, error) {
 	return resp.Items, nil
 }
 
+func (c *Client) Downloads() ([]DownloadQueueItem, error) {
+	var resp struct {
+		Items []DownloadQueueItem `json:"items"`
+	}
+	if err := c.do(http.MethodGet, "/downloads", nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Items == nil {
+		resp.Items = []DownloadQueueItem{}
+	}
+	return resp.Items, nil
+}
+
+func (c *Client) EnqueueDownload(url, mediaType string, track int) error {
+	return c.do(http.MethodPost, "/downloads", map[string]any{"url": url, "mediaType": mediaType, "track": track}, nil)
+}
+
+func (c *Client) MoveDownload(url, mediaType string, track, position int) error {
+	return c.do(http.MethodPost, "/downloads/move", map[string]any{"url": url, "mediaType": mediaType, "track": track, "position": position}, nil)
+}
+
+func (c *Client) PauseDownload(url, mediaType string, track int) error {
+	return c.do(http.MethodPost, "/downloads/pause", map[string]any{"url": url, "mediaType": mediaType, "track": track}, nil)
+}
+
+func (c *Client) ResumeDownload(url, mediaType string, track int) error {
+	return c.do(http.MethodPost, "/downloads/resume", map[string]any{"url": url, "mediaType": mediaType, "track": track}, nil)
+}
+
+func (c *Client) RemoveDownload(url, mediaType string, track int) error {
+	return c.do(http.MethodPost, "/downloads/remove", map[string]any{"url": url, "mediaType": mediaType, "track": track}, nil)
+}
+
 func (c *Client) LibraryTree() (*LibraryScanResult, error) {
 	var result LibraryScanResult
 	if err := c.do(http.MethodGet, "/library/tree", nil, &result); err != nil {
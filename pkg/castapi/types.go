@@ -23,6 +23,8 @@ type PlayOptions struct {
 	AudioTrack   int     `json:"audioTrack"`
 	SubtitlePath string  `json:"subtitlePath"`
 	Quality      *string `json:"quality"`
+	AspectRatio  string  `json:"aspectRatio,omitempty"`
+	Zoom         float64 `json:"zoom,omitempty"`
 }
 
 type LibraryItem struct {
@@ -47,6 +49,9 @@ type PlaybackState struct {
 type SubtitleDisplayItem struct {
 	Path  string
 	Label string
+	// BurnInOnly marks image-based tracks (PGS, VobSub, DVB), which are
+	// always burned into the video
+	BurnInOnly bool
 }
 
 type AudioTracksDisplayItem struct {
@@ -86,6 +91,15 @@ type TorrentStatus struct {
 	SavePath    string  `json:"save_path"`
 }
 
+type DownloadQueueItem struct {
+	URL       string `json:"url"`
+	MediaType string `json:"mediaType"`
+	Track     int    `json:"track"`
+	Priority  int    `json:"priority"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+}
+
 type LibraryEpisode struct {
 	Path         string `json:"path"`
 	Name         string `json:"name"`
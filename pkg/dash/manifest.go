@@ -0,0 +1,589 @@
+package dash
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wails-cast/pkg/hls"
+)
+
+// defaultTimeShiftBufferDepth is the live window used when a dynamic MPD
+// does not set one
+const defaultTimeShiftBufferDepth = 30 * time.Second
+
+// RangeLoader fetches length bytes of a file from offset, used to read the
+// segment index of SegmentBase representations
+type RangeLoader func(url *url.URL, offset int64, length int64) ([]byte, error)
+
+// Manifest is an MPD mapped onto the HLS model: every video representation
+// becomes a video variant and every audio or WebVTT representation an
+// audio or subtitle rendition. The tracks are those of the first period;
+// later periods play after them, following a discontinuity.
+type Manifest struct {
+	MPD      *MPD
+	URL      *url.URL
+	Playlist *hls.ManifestPlaylist
+	tracks   map[string][]*track
+}
+
+// track is a representation with the addressing it inherits from its
+// adaptation set and period
+type track struct {
+	Representation Representation
+	BaseURL        *url.URL
+	Template       *SegmentTemplate
+	List           *SegmentList
+	Base           *SegmentBase
+	PeriodStart    time.Duration
+	PeriodDuration time.Duration
+	// following holds the same track in the later periods, see follow
+	following []*track
+}
+
+// Parse parses an MPD fetched from manifestURL
+func Parse(content string, manifestURL *url.URL) (*Manifest, error) {
+	mpd, err := ParseMPD(content)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		MPD: mpd,
+		URL: manifestURL,
+		Playlist: &hls.ManifestPlaylist{
+			VideoTracks:    []hls.VideoTrack{},
+			AudioTracks:    []hls.AudioTrack{},
+			SubtitleTracks: []hls.SubtitleTrack{},
+		},
+		tracks: make(map[string][]*track),
+	}
+
+	starts, durations := periodTimes(mpd)
+	protected := false
+	audioCodecs := ""
+	for i, period := range mpd.Periods {
+		periodBase := resolveBaseURL(resolveBaseURL(manifestURL, mpd.BaseURL), period.BaseURL)
+		periodTracks := make(map[string][]*track)
+		for _, set := range period.AdaptationSets {
+			if len(set.ContentProtection) > 0 {
+				protected = protected || i == 0
+				continue
+			}
+			setBase := resolveBaseURL(periodBase, set.BaseURL)
+			for _, representation := range set.Representations {
+				inheritRepresentation(&representation, set)
+				t := &track{
+					Representation: representation,
+					BaseURL:        resolveBaseURL(setBase, representation.BaseURL),
+					Template:       mergeTemplate(mergeTemplate(period.SegmentTemplate, set.SegmentTemplate), representation.SegmentTemplate),
+					List:           firstNonNil(representation.SegmentList, set.SegmentList, period.SegmentList),
+					Base:           firstNonNil(representation.SegmentBase, set.SegmentBase, period.SegmentBase),
+					PeriodStart:    starts[i],
+					PeriodDuration: durations[i],
+				}
+
+				kind := contentType(set, representation)
+				if kind == "text" {
+					// Only WebVTT files can be served as subtitles
+					if representation.MimeType != "text/vtt" {
+						continue
+					}
+					kind = "subtitle"
+				}
+				if i > 0 {
+					periodTracks[kind] = append(periodTracks[kind], t)
+					continue
+				}
+
+				trackURL := *manifestURL
+				trackURL.Fragment = representation.ID
+
+				switch kind {
+				case "video":
+					manifest.Playlist.VideoTracks = append(manifest.Playlist.VideoTracks, hls.VideoTrack{
+						URI:        &trackURL,
+						Bandwidth:  representation.Bandwidth,
+						Codecs:     representation.Codecs,
+						Resolution: fmt.Sprintf("%dx%d", representation.Width, representation.Height),
+						FrameRate:  parseFrameRate(representation.FrameRate),
+						Attrs:      map[string]string{},
+						Index:      len(manifest.Playlist.VideoTracks),
+					})
+					manifest.tracks["video"] = append(manifest.tracks["video"], t)
+				case "audio":
+					if audioCodecs == "" {
+						audioCodecs = representation.Codecs
+					}
+					manifest.Playlist.AudioTracks = append(manifest.Playlist.AudioTracks, hls.AudioTrack{
+						URI:        &trackURL,
+						GroupID:    "audio",
+						Name:       trackName(set, representation),
+						Language:   set.Lang,
+						Default:    len(manifest.Playlist.AudioTracks) == 0,
+						Autoselect: true,
+						Channels:   channels(representation.AudioChannelConfiguration),
+						Attrs:      map[string]string{},
+						Index:      len(manifest.Playlist.AudioTracks),
+					})
+					manifest.tracks["audio"] = append(manifest.tracks["audio"], t)
+				case "subtitle":
+					manifest.Playlist.SubtitleTracks = append(manifest.Playlist.SubtitleTracks, hls.SubtitleTrack{
+						URI:        t.BaseURL,
+						GroupID:    "subs",
+						Name:       trackName(set, representation),
+						Language:   set.Lang,
+						Autoselect: true,
+						Attrs:      map[string]string{},
+						Index:      len(manifest.Playlist.SubtitleTracks),
+					})
+					manifest.tracks["subtitle"] = append(manifest.tracks["subtitle"], t)
+				}
+			}
+		}
+		if i > 0 {
+			manifest.follow(periodTracks)
+		}
+	}
+
+	if len(manifest.Playlist.VideoTracks) == 0 {
+		if protected {
+			return nil, fmt.Errorf("unsupported DASH stream: all video is DRM protected")
+		}
+		return nil, fmt.Errorf("invalid MPD: no video representations")
+	}
+
+	// Like HLS variants, the video tracks advertise the audio codec too
+	for i := range manifest.Playlist.VideoTracks {
+		variant := &manifest.Playlist.VideoTracks[i]
+		if len(manifest.Playlist.AudioTracks) > 0 {
+			variant.Audio = "audio"
+			if audioCodecs != "" && variant.Codecs != "" {
+				variant.Codecs += "," + audioCodecs
+			}
+		}
+		if len(manifest.Playlist.SubtitleTracks) > 0 {
+			variant.Subtitles = "subs"
+		}
+	}
+
+	return manifest, nil
+}
+
+// TrackPlaylist builds the media playlist of a track from its segment
+// addressing. now places the live edge of dynamic presentations.
+func (m *Manifest) TrackPlaylist(trackType string, index int, load RangeLoader, now time.Time) (*hls.TrackPlaylist, error) {
+	tracks := m.tracks[trackType]
+	if index < 0 || index >= len(tracks) {
+		return nil, fmt.Errorf("%s track index out of range", trackType)
+	}
+
+	playlist := &hls.TrackPlaylist{
+		Version: 6,
+		EndList: !m.MPD.Dynamic(),
+	}
+	if !m.MPD.Dynamic() {
+		playlist.PlaylistType = "VOD"
+	}
+
+	for i, t := range append([]*track{tracks[index]}, tracks[index].following...) {
+		period := &hls.TrackPlaylist{}
+		if err := m.periodSegments(trackType, t, period, load, now); err != nil {
+			return nil, err
+		}
+		for j, segment := range period.Segments {
+			segment.Map = period.Map
+			segment.Discontinuity = i > 0 && j == 0
+		}
+		// The first period numbers the segments
+		if i == 0 {
+			playlist.MediaSequence = period.MediaSequence
+			playlist.Map = period.Map
+		}
+		playlist.Segments = append(playlist.Segments, period.Segments...)
+	}
+	if len(playlist.Segments) == 0 {
+		return nil, fmt.Errorf("%s track %d has no segments", trackType, index)
+	}
+
+	longest := 0.0
+	for _, segment := range playlist.Segments {
+		longest = math.Max(longest, segment.Duration)
+	}
+	playlist.TargetDuration = int(math.Ceil(longest))
+	return playlist, nil
+}
+
+// periodSegments lists the segments of a track in one period from its
+// segment addressing
+func (m *Manifest) periodSegments(trackType string, t *track, playlist *hls.TrackPlaylist, load RangeLoader, now time.Time) error {
+	switch {
+	case trackType == "subtitle":
+		playlist.Segments = []*hls.Segment{{
+			Duration: t.PeriodDuration.Seconds(),
+			URI:      t.BaseURL,
+		}}
+		return nil
+	case t.Template != nil && t.Template.Media != "":
+		return m.templateSegments(t, playlist, now)
+	case t.List != nil:
+		return t.listSegments(playlist)
+	}
+	return t.baseSegments(playlist, load)
+}
+
+// follow appends the tracks of a later period to the tracks of the first
+// one: the representation with the same ID, or else the one at the same
+// position of its kind. Tracks without a counterpart skip the period.
+func (m *Manifest) follow(periodTracks map[string][]*track) {
+	for kind, tracks := range m.tracks {
+		candidates := periodTracks[kind]
+		if len(candidates) == 0 {
+			continue
+		}
+		for i, t := range tracks {
+			next := candidates[min(i, len(candidates)-1)]
+			for _, candidate := range candidates {
+				if candidate.Representation.ID == t.Representation.ID {
+					next = candidate
+					break
+				}
+			}
+			t.following = append(t.following, next)
+		}
+	}
+}
+
+// periodTimes returns the start and duration of every period. A period
+// without start follows the previous one, and one without duration lasts
+// until the next period or the end of the presentation.
+func periodTimes(mpd *MPD) ([]time.Duration, []time.Duration) {
+	starts := make([]time.Duration, len(mpd.Periods))
+	durations := make([]time.Duration, len(mpd.Periods))
+	for i, period := range mpd.Periods {
+		starts[i], _ = parseDuration(period.Start)
+		durations[i], _ = parseDuration(period.Duration)
+		if period.Start == "" && i > 0 {
+			starts[i] = starts[i-1] + durations[i-1]
+		}
+	}
+	for i := range mpd.Periods {
+		if durations[i] != 0 {
+			continue
+		}
+		end, _ := parseDuration(mpd.MediaPresentationDuration)
+		if i+1 < len(mpd.Periods) {
+			end = starts[i+1]
+		}
+		durations[i] = end - starts[i]
+	}
+	return starts, durations
+}
+
+// templateSegments expands a SegmentTemplate, from its timeline or from its
+// fixed segment duration
+func (m *Manifest) templateSegments(t *track, playlist *hls.TrackPlaylist, now time.Time) error {
+	template := t.Template
+	timescale := valueOr(template.Timescale, 1)
+	startNumber := valueOr(template.StartNumber, 1)
+	// $Time$ is on the media timeline, which starts at the offset
+	presentationTimeOffset := valueOr(template.PresentationTimeOffset, 0)
+	values := templateValues{
+		RepresentationID: t.Representation.ID,
+		Bandwidth:        t.Representation.Bandwidth,
+	}
+
+	if template.Initialization != "" {
+		playlist.Map = &hls.Map{URI: resolve(t.BaseURL, values.fill(template.Initialization)).String()}
+	}
+	add := func(number int64, start int64, duration int64) {
+		values.Number = number
+		values.Time = start
+		playlist.Segments = append(playlist.Segments, &hls.Segment{
+			Duration: float64(duration) / float64(timescale),
+			URI:      resolve(t.BaseURL, values.fill(template.Media)),
+		})
+	}
+	playlist.MediaSequence = int(startNumber)
+
+	if template.SegmentTimeline != nil {
+		entries := template.SegmentTimeline.S
+		periodEnd := int64(t.PeriodDuration.Seconds()*float64(timescale)) + presentationTimeOffset
+		// A dynamic period ends at the live edge, before which only complete
+		// segments are listed
+		elapsed, live := m.liveElapsed(t, now)
+		if live {
+			periodEnd = int64(elapsed.Seconds()*float64(timescale)) + presentationTimeOffset
+		}
+		number := startNumber
+		var start int64
+		var ends []int64
+		for i, entry := range entries {
+			if entry.T != nil {
+				start = *entry.T
+			}
+			if entry.D <= 0 {
+				return fmt.Errorf("invalid segment timeline duration %d", entry.D)
+			}
+			repeat := entry.R
+			// A negative repeat count lasts until the next entry or the
+			// end of the period
+			if repeat < 0 {
+				switch {
+				case i+1 < len(entries) && entries[i+1].T != nil:
+					repeat = (*entries[i+1].T-start+entry.D-1)/entry.D - 1
+				case live:
+					repeat = (periodEnd-start)/entry.D - 1
+				default:
+					repeat = (periodEnd-start+entry.D-1)/entry.D - 1
+				}
+			}
+			for r := int64(0); r <= repeat; r++ {
+				add(number, start, entry.D)
+				number++
+				start += entry.D
+				ends = append(ends, start)
+			}
+		}
+
+		// Like liveWindow, drop the segments older than the time shift buffer
+		if live {
+			windowStart := periodEnd - int64(m.timeShiftBufferDepth().Seconds()*float64(timescale))
+			trimmed := 0
+			for trimmed < len(ends) && ends[trimmed] <= windowStart {
+				trimmed++
+			}
+			playlist.Segments = playlist.Segments[trimmed:]
+			playlist.MediaSequence += trimmed
+		}
+		return nil
+	}
+
+	duration := valueOr(template.Duration, 0)
+	if duration <= 0 {
+		return fmt.Errorf("segment template has neither a timeline nor a duration")
+	}
+	first, count := int64(0), int64(math.Ceil(t.PeriodDuration.Seconds()*float64(timescale)/float64(duration)))
+	if m.MPD.Dynamic() {
+		first, count = m.liveWindow(t, timescale, duration, now)
+	}
+	playlist.MediaSequence = int(startNumber + first)
+	for i := first; i < first+count; i++ {
+		add(startNumber+i, i*duration+presentationTimeOffset, duration)
+	}
+	return nil
+}
+
+// liveWindow returns the first segment and the number of segments of a
+// dynamic template that are available at now
+func (m *Manifest) liveWindow(t *track, timescale int64, duration int64, now time.Time) (int64, int64) {
+	elapsed, found := m.liveElapsed(t, now)
+	if !found {
+		return 0, 0
+	}
+	// Only segments that ended before now are complete
+	available := int64(elapsed.Seconds() * float64(timescale) / float64(duration))
+	window := int64(math.Ceil(m.timeShiftBufferDepth().Seconds() * float64(timescale) / float64(duration)))
+	first := max(available-window, 0)
+	return first, max(available-first, 0)
+}
+
+// liveElapsed returns how far into the period of t the live edge of a
+// dynamic presentation is at now. It is not found for static presentations
+// or without an availability start time.
+func (m *Manifest) liveElapsed(t *track, now time.Time) (time.Duration, bool) {
+	if !m.MPD.Dynamic() {
+		return 0, false
+	}
+	availabilityStart, err := time.Parse(time.RFC3339, m.MPD.AvailabilityStartTime)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(availabilityStart) - t.PeriodStart, true
+}
+
+// timeShiftBufferDepth returns how far behind the live edge segments of a
+// dynamic presentation stay available
+func (m *Manifest) timeShiftBufferDepth() time.Duration {
+	depth, _ := parseDuration(m.MPD.TimeShiftBufferDepth)
+	if depth == 0 {
+		return defaultTimeShiftBufferDepth
+	}
+	return depth
+}
+
+// listSegments maps a SegmentList, whose entries may be byte ranges of one file
+func (t *track) listSegments(playlist *hls.TrackPlaylist) error {
+	timescale := valueOr(t.List.Timescale, 1)
+	duration := float64(valueOr(t.List.Duration, 0)) / float64(timescale)
+
+	if initialization := t.List.Initialization; initialization != nil {
+		initMap, err := t.initMap(initialization)
+		if err != nil {
+			return err
+		}
+		playlist.Map = initMap
+	}
+
+	for _, segmentURL := range t.List.SegmentURLs {
+		segment := &hls.Segment{
+			Duration: duration,
+			URI:      resolve(t.BaseURL, segmentURL.Media),
+		}
+		if segmentURL.MediaRange != "" {
+			offset, length, err := parseRange(segmentURL.MediaRange)
+			if err != nil {
+				return err
+			}
+			segment.ByteRange = &hls.ByteRange{Offset: offset, Length: length}
+		}
+		playlist.Segments = append(playlist.Segments, segment)
+	}
+	return nil
+}
+
+// baseSegments maps a single-file representation onto byte range segments
+// listed by its segment index
+func (t *track) baseSegments(playlist *hls.TrackPlaylist, load RangeLoader) error {
+	if t.Base == nil || t.Base.IndexRange == "" {
+		return fmt.Errorf("representation %s has no segment index", t.Representation.ID)
+	}
+	if initialization := t.Base.Initialization; initialization != nil {
+		initMap, err := t.initMap(initialization)
+		if err != nil {
+			return err
+		}
+		playlist.Map = initMap
+	}
+
+	offset, length, err := parseRange(t.Base.IndexRange)
+	if err != nil {
+		return err
+	}
+	data, err := load(t.BaseURL, offset, length)
+	if err != nil {
+		return fmt.Errorf("failed to load segment index: %w", err)
+	}
+	references, err := ParseSidx(data, offset)
+	if err != nil {
+		return err
+	}
+	for _, reference := range references {
+		playlist.Segments = append(playlist.Segments, &hls.Segment{
+			Duration:  reference.Duration,
+			URI:       t.BaseURL,
+			ByteRange: &hls.ByteRange{Offset: reference.Offset, Length: reference.Length},
+		})
+	}
+	return nil
+}
+
+// initMap maps an Initialization element onto an init section
+func (t *track) initMap(initialization *URLType) (*hls.Map, error) {
+	initMap := &hls.Map{URI: resolve(t.BaseURL, initialization.SourceURL).String()}
+	if initialization.Range != "" {
+		offset, length, err := parseRange(initialization.Range)
+		if err != nil {
+			return nil, err
+		}
+		initMap.ByteRange = &hls.ByteRange{Offset: offset, Length: length}
+	}
+	return initMap, nil
+}
+
+// inheritRepresentation fills the attributes a representation inherits from
+// its adaptation set
+func inheritRepresentation(representation *Representation, set AdaptationSet) {
+	if representation.MimeType == "" {
+		representation.MimeType = set.MimeType
+	}
+	if representation.Codecs == "" {
+		representation.Codecs = set.Codecs
+	}
+	if representation.Width == 0 {
+		representation.Width = set.Width
+	}
+	if representation.Height == 0 {
+		representation.Height = set.Height
+	}
+	if representation.FrameRate == "" {
+		representation.FrameRate = set.FrameRate
+	}
+	if len(representation.AudioChannelConfiguration) == 0 {
+		representation.AudioChannelConfiguration = set.AudioChannelConfiguration
+	}
+}
+
+// contentType returns "video", "audio" or "text"
+func contentType(set AdaptationSet, representation Representation) string {
+	if set.ContentType != "" {
+		return set.ContentType
+	}
+	kind, _, _ := strings.Cut(representation.MimeType, "/")
+	return kind
+}
+
+// trackName labels an audio or subtitle track
+func trackName(set AdaptationSet, representation Representation) string {
+	switch {
+	case set.Label != "":
+		return set.Label
+	case set.Lang != "":
+		return set.Lang
+	}
+	return representation.ID
+}
+
+// channels returns the channel count of an AudioChannelConfiguration, or ""
+// when it is not a plain count
+func channels(configurations []Descriptor) string {
+	for _, configuration := range configurations {
+		if _, err := strconv.Atoi(configuration.Value); err == nil {
+			return configuration.Value
+		}
+	}
+	return ""
+}
+
+// parseFrameRate parses "25" or "30000/1001"
+func parseFrameRate(value string) float64 {
+	numerator, denominator, found := strings.Cut(value, "/")
+	rate, _ := strconv.ParseFloat(numerator, 64)
+	if found {
+		if divisor, _ := strconv.ParseFloat(denominator, 64); divisor > 0 {
+			rate /= divisor
+		}
+	}
+	return rate
+}
+
+// resolveBaseURL resolves the first BaseURL of an element against the base
+// of its parent
+func resolveBaseURL(parent *url.URL, baseURLs []string) *url.URL {
+	if len(baseURLs) == 0 {
+		return parent
+	}
+	return resolve(parent, strings.TrimSpace(baseURLs[0]))
+}
+
+// resolve resolves a reference against a base URL, returning the base itself
+// for an empty or invalid reference
+func resolve(base *url.URL, reference string) *url.URL {
+	parsed, err := url.Parse(reference)
+	if err != nil || reference == "" {
+		return base
+	}
+	return base.ResolveReference(parsed)
+}
+
+func firstNonNil[T any](values ...*T) *T {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
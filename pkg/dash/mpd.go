@@ -0,0 +1,202 @@
+package dash
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MPD is the root of a DASH media presentation description
+type MPD struct {
+	Type                      string   `xml:"type,attr"` // static or dynamic
+	MediaPresentationDuration string   `xml:"mediaPresentationDuration,attr"`
+	MinimumUpdatePeriod       string   `xml:"minimumUpdatePeriod,attr"`
+	AvailabilityStartTime     string   `xml:"availabilityStartTime,attr"`
+	TimeShiftBufferDepth      string   `xml:"timeShiftBufferDepth,attr"`
+	BaseURL                   []string `xml:"BaseURL"`
+	Periods                   []Period `xml:"Period"`
+}
+
+// Period is one part of the presentation timeline
+type Period struct {
+	ID              string           `xml:"id,attr"`
+	Start           string           `xml:"start,attr"`
+	Duration        string           `xml:"duration,attr"`
+	BaseURL         []string         `xml:"BaseURL"`
+	SegmentTemplate *SegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList     *SegmentList     `xml:"SegmentList"`
+	SegmentBase     *SegmentBase     `xml:"SegmentBase"`
+	AdaptationSets  []AdaptationSet  `xml:"AdaptationSet"`
+}
+
+// AdaptationSet groups interchangeable representations of one component
+type AdaptationSet struct {
+	ID                        string           `xml:"id,attr"`
+	ContentType               string           `xml:"contentType,attr"`
+	MimeType                  string           `xml:"mimeType,attr"`
+	Codecs                    string           `xml:"codecs,attr"`
+	Lang                      string           `xml:"lang,attr"`
+	Label                     string           `xml:"label,attr"`
+	Width                     int              `xml:"width,attr"`
+	Height                    int              `xml:"height,attr"`
+	FrameRate                 string           `xml:"frameRate,attr"`
+	BaseURL                   []string         `xml:"BaseURL"`
+	Roles                     []Descriptor     `xml:"Role"`
+	AudioChannelConfiguration []Descriptor     `xml:"AudioChannelConfiguration"`
+	ContentProtection         []Descriptor     `xml:"ContentProtection"`
+	SegmentTemplate           *SegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList               *SegmentList     `xml:"SegmentList"`
+	SegmentBase               *SegmentBase     `xml:"SegmentBase"`
+	Representations           []Representation `xml:"Representation"`
+}
+
+// Representation is one encoding of an adaptation set
+type Representation struct {
+	ID                        string           `xml:"id,attr"`
+	Bandwidth                 int              `xml:"bandwidth,attr"`
+	MimeType                  string           `xml:"mimeType,attr"`
+	Codecs                    string           `xml:"codecs,attr"`
+	Width                     int              `xml:"width,attr"`
+	Height                    int              `xml:"height,attr"`
+	FrameRate                 string           `xml:"frameRate,attr"`
+	BaseURL                   []string         `xml:"BaseURL"`
+	AudioChannelConfiguration []Descriptor     `xml:"AudioChannelConfiguration"`
+	SegmentTemplate           *SegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList               *SegmentList     `xml:"SegmentList"`
+	SegmentBase               *SegmentBase     `xml:"SegmentBase"`
+}
+
+// Descriptor is a scheme/value pair such as Role or AudioChannelConfiguration
+type Descriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+// SegmentTemplate addresses segments through a URL template
+type SegmentTemplate struct {
+	Timescale              *int64           `xml:"timescale,attr"`
+	Duration               *int64           `xml:"duration,attr"`
+	StartNumber            *int64           `xml:"startNumber,attr"`
+	PresentationTimeOffset *int64           `xml:"presentationTimeOffset,attr"`
+	Media                  string           `xml:"media,attr"`
+	Initialization         string           `xml:"initialization,attr"`
+	SegmentTimeline        *SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+// SegmentTimeline lists segment times and durations explicitly
+type SegmentTimeline struct {
+	S []TimelineEntry `xml:"S"`
+}
+
+// TimelineEntry is a run of R+1 segments of duration D starting at T
+type TimelineEntry struct {
+	T *int64 `xml:"t,attr"`
+	D int64  `xml:"d,attr"`
+	R int64  `xml:"r,attr"`
+}
+
+// SegmentList enumerates segment URLs
+type SegmentList struct {
+	Timescale      *int64       `xml:"timescale,attr"`
+	Duration       *int64       `xml:"duration,attr"`
+	Initialization *URLType     `xml:"Initialization"`
+	SegmentURLs    []SegmentURL `xml:"SegmentURL"`
+}
+
+// SegmentURL is one entry of a segment list
+type SegmentURL struct {
+	Media      string `xml:"media,attr"`
+	MediaRange string `xml:"mediaRange,attr"`
+}
+
+// SegmentBase describes a single-file representation indexed by a sidx box
+type SegmentBase struct {
+	Timescale      *int64   `xml:"timescale,attr"`
+	IndexRange     string   `xml:"indexRange,attr"`
+	Initialization *URLType `xml:"Initialization"`
+}
+
+// URLType addresses an initialization section
+type URLType struct {
+	SourceURL string `xml:"sourceURL,attr"`
+	Range     string `xml:"range,attr"`
+}
+
+// IsManifest reports whether content looks like a DASH MPD rather than an
+// HLS playlist
+func IsManifest(content string) bool {
+	content = strings.TrimSpace(content)
+	return !strings.HasPrefix(content, "#EXTM3U") && strings.Contains(content, "<MPD")
+}
+
+// ParseMPD parses an MPD document
+func ParseMPD(content string) (*MPD, error) {
+	mpd := &MPD{}
+	if err := xml.Unmarshal([]byte(content), mpd); err != nil {
+		return nil, fmt.Errorf("invalid MPD: %w", err)
+	}
+	if len(mpd.Periods) == 0 {
+		return nil, fmt.Errorf("invalid MPD: no periods")
+	}
+	return mpd, nil
+}
+
+// Dynamic reports whether the presentation is live and must be reloaded
+func (m *MPD) Dynamic() bool {
+	return m.Type == "dynamic"
+}
+
+// parseDuration parses an xs:duration such as "PT1H2M3.5S". Years and
+// months are not used by MPDs and are rejected.
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	rest, found := strings.CutPrefix(value, "P")
+	if !found {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+
+	var total time.Duration
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+		end := strings.IndexAny(rest, "DHMS")
+		if end <= 0 {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		var amount float64
+		if _, err := fmt.Sscanf(rest[:end], "%g", &amount); err != nil {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		unit := rest[end]
+		switch {
+		case unit == 'D' && !inTime:
+			total += time.Duration(amount * float64(24*time.Hour))
+		case unit == 'H' && inTime:
+			total += time.Duration(amount * float64(time.Hour))
+		case unit == 'M' && inTime:
+			total += time.Duration(amount * float64(time.Minute))
+		case unit == 'S' && inTime:
+			total += time.Duration(amount * float64(time.Second))
+		default:
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		rest = rest[end+1:]
+	}
+	return total, nil
+}
+
+// parseRange parses a byte range "first-last" into an offset and a length
+func parseRange(value string) (int64, int64, error) {
+	var first, last int64
+	if _, err := fmt.Sscanf(value, "%d-%d", &first, &last); err != nil || last < first {
+		return 0, 0, fmt.Errorf("invalid byte range %s", value)
+	}
+	return first, last - first + 1, nil
+}
@@ -0,0 +1,94 @@
+package dash
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// SidxReference is one subsegment listed by a segment index box
+type SidxReference struct {
+	Offset   int64 // Absolute offset in the file
+	Length   int64
+	Duration float64 // Seconds
+}
+
+// ParseSidx reads the segment index box (sidx) of a SegmentBase
+// representation. data holds the bytes of the index range, which starts at
+// offset indexStart in the file.
+func ParseSidx(data []byte, indexStart int64) ([]SidxReference, error) {
+	box, boxStart, err := findBox(data, "sidx")
+	if err != nil {
+		return nil, err
+	}
+	// References are addressed from the first byte after the box
+	anchor := indexStart + int64(boxStart) + int64(len(box)) + 8
+
+	if len(box) < 12 {
+		return nil, fmt.Errorf("sidx box too short")
+	}
+	version := box[0]
+	timescale := binary.BigEndian.Uint32(box[8:12])
+	if timescale == 0 {
+		return nil, fmt.Errorf("sidx box has no timescale")
+	}
+	pos := 12
+	var firstOffset uint64
+	if version == 0 {
+		if len(box) < pos+8 {
+			return nil, fmt.Errorf("sidx box too short")
+		}
+		firstOffset = uint64(binary.BigEndian.Uint32(box[pos+4 : pos+8]))
+		pos += 8
+	} else {
+		if len(box) < pos+16 {
+			return nil, fmt.Errorf("sidx box too short")
+		}
+		firstOffset = binary.BigEndian.Uint64(box[pos+8 : pos+16])
+		pos += 16
+	}
+	if len(box) < pos+4 {
+		return nil, fmt.Errorf("sidx box too short")
+	}
+	count := int(binary.BigEndian.Uint16(box[pos+2 : pos+4]))
+	pos += 4
+	if len(box) < pos+count*12 {
+		return nil, fmt.Errorf("sidx box lists %d references but is too short", count)
+	}
+
+	references := make([]SidxReference, 0, count)
+	offset := anchor + int64(firstOffset)
+	for i := 0; i < count; i++ {
+		entry := box[pos+i*12:]
+		size := binary.BigEndian.Uint32(entry[0:4])
+		// Hierarchical indexes reference further sidx boxes
+		if size&0x80000000 != 0 {
+			return nil, fmt.Errorf("hierarchical sidx boxes are not supported")
+		}
+		length := int64(size & 0x7FFFFFFF)
+		duration := binary.BigEndian.Uint32(entry[4:8])
+		references = append(references, SidxReference{
+			Offset:   offset,
+			Length:   length,
+			Duration: float64(duration) / float64(timescale),
+		})
+		offset += length
+	}
+	return references, nil
+}
+
+// findBox returns the payload of the first top-level ISO BMFF box of a type
+// and the offset of its header in data
+func findBox(data []byte, boxType string) ([]byte, int, error) {
+	pos := 0
+	for pos+8 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		if size < 8 || pos+size > len(data) {
+			break
+		}
+		if string(data[pos+4:pos+8]) == boxType {
+			return data[pos+8 : pos+size], pos, nil
+		}
+		pos += size
+	}
+	return nil, 0, fmt.Errorf("%s box not found", boxType)
+}
@@ -0,0 +1,84 @@
+package dash
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// templateIdentifier matches $Identifier$, $Identifier%0Nd$ and the escaped
+// dollar sign $$ in templates
+var templateIdentifier = regexp.MustCompile(`\$(?:(RepresentationID|Number|Bandwidth|Time)(?:%0(\d+)d)?)?\$`)
+
+// templateValues are the substitutions of one segment URL
+type templateValues struct {
+	RepresentationID string
+	Number           int64
+	Bandwidth        int
+	Time             int64
+}
+
+// fill expands the identifiers of a SegmentTemplate media or initialization
+// attribute
+func (v templateValues) fill(template string) string {
+	return templateIdentifier.ReplaceAllStringFunc(template, func(match string) string {
+		groups := templateIdentifier.FindStringSubmatch(match)
+		var value int64
+		switch groups[1] {
+		case "":
+			return "$"
+		case "RepresentationID":
+			return v.RepresentationID
+		case "Number":
+			value = v.Number
+		case "Bandwidth":
+			value = int64(v.Bandwidth)
+		case "Time":
+			value = v.Time
+		}
+		width, _ := strconv.Atoi(groups[2])
+		return fmt.Sprintf("%0*d", width, value)
+	})
+}
+
+// mergeTemplate returns a template whose unset attributes are inherited from
+// the template of the enclosing element
+func mergeTemplate(parent *SegmentTemplate, child *SegmentTemplate) *SegmentTemplate {
+	if parent == nil {
+		return child
+	}
+	if child == nil {
+		return parent
+	}
+	merged := *parent
+	if child.Timescale != nil {
+		merged.Timescale = child.Timescale
+	}
+	if child.Duration != nil {
+		merged.Duration = child.Duration
+	}
+	if child.StartNumber != nil {
+		merged.StartNumber = child.StartNumber
+	}
+	if child.PresentationTimeOffset != nil {
+		merged.PresentationTimeOffset = child.PresentationTimeOffset
+	}
+	if child.Media != "" {
+		merged.Media = child.Media
+	}
+	if child.Initialization != "" {
+		merged.Initialization = child.Initialization
+	}
+	if child.SegmentTimeline != nil {
+		merged.SegmentTimeline = child.SegmentTimeline
+	}
+	return &merged
+}
+
+// valueOr dereferences an optional attribute
+func valueOr(value *int64, fallback int64) int64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
@@ -0,0 +1,287 @@
+package dash
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateFill(t *testing.T) {
+	values := templateValues{RepresentationID: "video-1080", Number: 42, Bandwidth: 5000000, Time: 180000}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: "$RepresentationID$/$Number$.m4s", want: "video-1080/42.m4s"},
+		{template: "seg-$Number%05d$.m4s", want: "seg-00042.m4s"},
+		{template: "$Bandwidth$/$Time$.mp4", want: "5000000/180000.mp4"},
+		{template: "t$Time%012d$.mp4", want: "t000000180000.mp4"},
+		{template: "price$$-$Number$", want: "price$-42"},
+		{template: "init.mp4", want: "init.mp4"},
+		{template: "$Unknown$-$Number$", want: "$Unknown$-42"},
+	}
+	for _, tt := range tests {
+		if got := values.fill(tt.template); got != tt.want {
+			t.Errorf("fill(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+// templateMPD returns a static MPD of one video representation addressed by
+// template, lasting duration
+func templateMPD(mpdAttrs string, duration string, template string) string {
+	return fmt.Sprintf(`<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" %s mediaPresentationDuration="%s">
+  <Period>
+    <AdaptationSet contentType="video" mimeType="video/mp4">
+      %s
+      <Representation id="v1" bandwidth="1000000" codecs="avc1.640028" width="1920" height="1080"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`, mpdAttrs, duration, template)
+}
+
+func TestTemplateSegments(t *testing.T) {
+	manifestURL, _ := url.Parse("https://cdn.example.com/show/manifest.mpd")
+	liveStart := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		mpd      string
+		now      time.Time
+		sequence int
+		// uris are relative to the manifest folder
+		uris      []string
+		durations []float64
+		wantErr   bool
+	}{
+		{
+			name:      "fixed duration",
+			mpd:       templateMPD(`type="static"`, "PT10S", `<SegmentTemplate timescale="1000" duration="4000" startNumber="1" media="$RepresentationID$/$Number$.m4s" initialization="$RepresentationID$/init.mp4"/>`),
+			sequence:  1,
+			uris:      []string{"v1/1.m4s", "v1/2.m4s", "v1/3.m4s"},
+			durations: []float64{4, 4, 4},
+		},
+		{
+			name:      "default start number and timescale",
+			mpd:       templateMPD(`type="static"`, "PT6S", `<SegmentTemplate duration="2" media="seg-$Number%03d$.m4s"/>`),
+			sequence:  1,
+			uris:      []string{"seg-001.m4s", "seg-002.m4s", "seg-003.m4s"},
+			durations: []float64{2, 2, 2},
+		},
+		{
+			name: "timeline with repeats",
+			mpd: templateMPD(`type="static"`, "PT10S", `<SegmentTemplate timescale="90000" startNumber="5" media="$Time$.m4s">
+        <SegmentTimeline><S t="0" d="180000" r="2"/><S d="90000"/></SegmentTimeline>
+      </SegmentTemplate>`),
+			sequence:  5,
+			uris:      []string{"0.m4s", "180000.m4s", "360000.m4s", "540000.m4s"},
+			durations: []float64{2, 2, 2, 1},
+		},
+		{
+			name: "negative repeat until the next entry",
+			mpd: templateMPD(`type="static"`, "PT10S", `<SegmentTemplate timescale="10" media="$Number$-$Time$.m4s">
+        <SegmentTimeline><S t="0" d="20" r="-1"/><S t="60" d="40"/></SegmentTimeline>
+      </SegmentTemplate>`),
+			sequence:  1,
+			uris:      []string{"1-0.m4s", "2-20.m4s", "3-40.m4s", "4-60.m4s"},
+			durations: []float64{2, 2, 2, 4},
+		},
+		{
+			name: "negative repeat until the end of the period",
+			mpd: templateMPD(`type="static"`, "PT7S", `<SegmentTemplate timescale="1" media="$Number$.m4s">
+        <SegmentTimeline><S t="0" d="3" r="-1"/></SegmentTimeline>
+      </SegmentTemplate>`),
+			sequence:  1,
+			uris:      []string{"1.m4s", "2.m4s", "3.m4s"},
+			durations: []float64{3, 3, 3},
+		},
+		{
+			name:      "live window",
+			mpd:       templateMPD(`type="dynamic" availabilityStartTime="2024-01-01T12:00:00Z" timeShiftBufferDepth="PT6S"`, "PT0S", `<SegmentTemplate timescale="1" duration="2" startNumber="10" media="$Number$.m4s"/>`),
+			now:       liveStart.Add(21 * time.Second),
+			sequence:  17,
+			uris:      []string{"17.m4s", "18.m4s", "19.m4s"},
+			durations: []float64{2, 2, 2},
+		},
+		{
+			name: "live timeline ends at the live edge",
+			mpd: templateMPD(`type="dynamic" availabilityStartTime="2024-01-01T12:00:00Z" timeShiftBufferDepth="PT6S"`, "PT0S", `<SegmentTemplate timescale="1" media="$Number$-$Time$.m4s">
+        <SegmentTimeline><S t="0" d="2" r="-1"/></SegmentTimeline>
+      </SegmentTemplate>`),
+			now:       liveStart.Add(21 * time.Second),
+			sequence:  8,
+			uris:      []string{"8-14.m4s", "9-16.m4s", "10-18.m4s"},
+			durations: []float64{2, 2, 2},
+		},
+		{
+			name: "live timeline with a presentation time offset",
+			mpd: templateMPD(`type="dynamic" availabilityStartTime="2024-01-01T12:00:00Z" timeShiftBufferDepth="PT6S"`, "PT0S", `<SegmentTemplate timescale="10" presentationTimeOffset="1000" media="$Time$.m4s">
+        <SegmentTimeline><S t="1000" d="20" r="-1"/></SegmentTimeline>
+      </SegmentTemplate>`),
+			now:       liveStart.Add(21 * time.Second),
+			sequence:  8,
+			uris:      []string{"1140.m4s", "1160.m4s", "1180.m4s"},
+			durations: []float64{2, 2, 2},
+		},
+		{
+			name:      "fixed duration with a presentation time offset",
+			mpd:       templateMPD(`type="static"`, "PT10S", `<SegmentTemplate timescale="1000" duration="4000" presentationTimeOffset="500" media="$Time$.m4s"/>`),
+			sequence:  1,
+			uris:      []string{"500.m4s", "4500.m4s", "8500.m4s"},
+			durations: []float64{4, 4, 4},
+		},
+		{
+			name:    "live before the first segment",
+			mpd:     templateMPD(`type="dynamic" availabilityStartTime="2024-01-01T12:00:00Z"`, "PT0S", `<SegmentTemplate timescale="1" duration="2" media="$Number$.m4s"/>`),
+			now:     liveStart.Add(time.Second),
+			wantErr: true,
+		},
+		{
+			name:    "no timeline nor duration",
+			mpd:     templateMPD(`type="static"`, "PT10S", `<SegmentTemplate media="$Number$.m4s"/>`),
+			wantErr: true,
+		},
+		{
+			name: "zero timeline duration",
+			mpd: templateMPD(`type="static"`, "PT10S", `<SegmentTemplate media="$Number$.m4s">
+        <SegmentTimeline><S t="0" d="0"/></SegmentTimeline>
+      </SegmentTemplate>`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := Parse(tt.mpd, manifestURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			playlist, err := manifest.TrackPlaylist("video", 0, nil, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if playlist.MediaSequence != tt.sequence {
+				t.Errorf("media sequence = %d, want %d", playlist.MediaSequence, tt.sequence)
+			}
+			var uris []string
+			var durations []float64
+			for _, segment := range playlist.Segments {
+				uris = append(uris, segment.URI.String())
+				durations = append(durations, segment.Duration)
+			}
+			var want []string
+			for _, uri := range tt.uris {
+				want = append(want, "https://cdn.example.com/show/"+uri)
+			}
+			if !slices.Equal(uris, want) {
+				t.Errorf("segments = %v, want %v", uris, want)
+			}
+			if !slices.Equal(durations, tt.durations) {
+				t.Errorf("durations = %v, want %v", durations, tt.durations)
+			}
+		})
+	}
+}
+
+func TestPeriods(t *testing.T) {
+	manifestURL, _ := url.Parse("https://cdn.example.com/show/manifest.mpd")
+	secondSets := `<AdaptationSet contentType="video" mimeType="video/mp4">
+      <SegmentTemplate timescale="1" duration="2" media="p2/$RepresentationID$/$Number$.m4s" initialization="p2/init.mp4"/>
+      <Representation id="v0" bandwidth="500000" codecs="avc1.640028" width="1280" height="720"/>
+      <Representation id="v1" bandwidth="1000000" codecs="avc1.640028" width="1920" height="1080"/>
+    </AdaptationSet>`
+
+	tests := []struct {
+		name string
+		// attrs time the second period
+		attrs string
+	}{
+		{name: "durations", attrs: `duration="PT4S"`},
+		{name: "starts", attrs: `start="PT6S"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mpd := fmt.Sprintf(`<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT10S">
+  <Period duration="PT6S">
+    <AdaptationSet contentType="video" mimeType="video/mp4">
+      <SegmentTemplate timescale="1" duration="2" media="p1/$Number$.m4s" initialization="p1/init.mp4"/>
+      <Representation id="v1" bandwidth="1000000" codecs="avc1.640028" width="1920" height="1080"/>
+    </AdaptationSet>
+  </Period>
+  <Period %s>
+    %s
+  </Period>
+</MPD>`, tt.attrs, secondSets)
+			manifest, err := Parse(mpd, manifestURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(manifest.Playlist.VideoTracks) != 1 {
+				t.Fatalf("%d video tracks, want those of the first period", len(manifest.Playlist.VideoTracks))
+			}
+			playlist, err := manifest.TrackPlaylist("video", 0, nil, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The second period continues the representation with the same ID
+			var uris, maps []string
+			var discontinuities []int
+			for i, segment := range playlist.Segments {
+				uris = append(uris, strings.TrimPrefix(segment.URI.String(), "https://cdn.example.com/show/"))
+				maps = append(maps, strings.TrimPrefix(segment.Map.URI, "https://cdn.example.com/show/"))
+				if segment.Discontinuity {
+					discontinuities = append(discontinuities, i)
+				}
+			}
+			if want := []string{"p1/1.m4s", "p1/2.m4s", "p1/3.m4s", "p2/v1/1.m4s", "p2/v1/2.m4s"}; !slices.Equal(uris, want) {
+				t.Errorf("segments = %v, want %v", uris, want)
+			}
+			if want := []string{"p1/init.mp4", "p1/init.mp4", "p1/init.mp4", "p2/init.mp4", "p2/init.mp4"}; !slices.Equal(maps, want) {
+				t.Errorf("init sections = %v, want %v", maps, want)
+			}
+			if want := []int{3}; !slices.Equal(discontinuities, want) {
+				t.Errorf("discontinuities = %v, want %v", discontinuities, want)
+			}
+			if playlist.MediaSequence != 1 {
+				t.Errorf("media sequence = %d, want 1", playlist.MediaSequence)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "PT30S", want: 30 * time.Second},
+		{value: "PT1H2M3.5S", want: time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{value: "P1DT12H", want: 36 * time.Hour},
+		{value: "PT0S", want: 0},
+		{value: "30S", wantErr: true},
+		{value: "P1M", wantErr: true},
+		{value: "PT1D", wantErr: true},
+		{value: "PTxS", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -55,7 +55,7 @@ type interceptRule struct {
 	handler lua.LValue
 }
 
-// Extract navigates to the page and extracts the HLS stream.
+// Extract navigates to the page and extracts the HLS or DASH stream.
 //
 // It first looks for a Lua script in {configDir}/scripts/ whose match_patterns
 // match the URL. Whether or not a script is found, the same core extraction
@@ -200,16 +200,14 @@ func extract(pageURL string, scriptPath string) (*ExtractResult, error) {
 		// Default detection: capture the manifest body and the real headers the
 		// browser used. This is the correct Referer — the m3u8's own origin is
 		// often wrong (embed on domain A, CDN on domain B gated by Referer: A).
-		if !manifestFound && (strings.Contains(contentType, "application/vnd.apple.mpegurl") ||
-			strings.Contains(contentType, "application/x-mpegURL") ||
-			strings.Contains(contentType, "mpegurl")) {
+		if !manifestFound && isManifestContentType(contentType) {
 			hlsURL = reqURL
 			manifestBody = ctx.Response.Body()
 			capReferer = ctx.Request.Header("Referer")
 			capOrigin = ctx.Request.Header("Origin")
 			capCookie = ctx.Request.Header("Cookie")
 			manifestFound = true
-			fmt.Printf("Found HLS stream: %s (Content-Type: %s, Referer: %s)\n", hlsURL, contentType, capReferer)
+			fmt.Printf("Found stream manifest: %s (Content-Type: %s, Referer: %s)\n", hlsURL, contentType, capReferer)
 		}
 
 		// Subtitles (VTT), collected regardless of scripted/generic.
@@ -349,6 +347,15 @@ func extract(pageURL string, scriptPath string) (*ExtractResult, error) {
 	}, nil
 }
 
+// isManifestContentType reports whether a response is an HLS playlist or a
+// DASH MPD
+func isManifestContentType(contentType string) bool {
+	return strings.Contains(contentType, "application/vnd.apple.mpegurl") ||
+		strings.Contains(contentType, "application/x-mpegURL") ||
+		strings.Contains(contentType, "mpegurl") ||
+		strings.Contains(contentType, "application/dash+xml")
+}
+
 // findScript scans all Lua scripts for match_patterns matching the URL.
 func findScript(pageURL string) string {
 	scriptsDir := filepath.Join(folders.GetConfig(), "scripts")
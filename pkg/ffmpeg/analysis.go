@@ -0,0 +1,108 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// VideoAnalysis is the result of scanning a video for interlacing and black
+// bars
+type VideoAnalysis struct {
+	Interlaced bool
+	// Crop is the picture area inside the black bars as "w:h:x:y", or ""
+	// when there is nothing to crop
+	Crop string
+}
+
+const (
+	// analysisWindows sample windows are spread over the video
+	analysisWindows = 3
+	// analysisWindowDuration is the length of each window in seconds
+	analysisWindowDuration = 10.0
+	// minCropPixels ignores detected bars thinner than this
+	minCropPixels = 8
+)
+
+var (
+	// "[Parsed_idet_0 @ 0x...] Multi frame detection: TFF: 12 BFF: 0 Progressive: 230 Undetermined: 8"
+	idetPattern = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s*BFF:\s*(\d+)\s*Progressive:\s*(\d+)`)
+	// "[Parsed_cropdetect_1 @ 0x...] x1:0 x2:1919 y1:138 y2:941 w:1920 h:800 x:0 y:140 pts:... crop=1920:800:0:140"
+	cropdetectPattern = regexp.MustCompile(`crop=(-?\d+):(-?\d+):(-?\d+):(-?\d+)`)
+)
+
+// AnalyzeVideo runs idet and cropdetect on a few windows spread over the
+// video. Black bars are the union of the picture areas found in each window,
+// so a dark scene cannot crop into the picture.
+func AnalyzeVideo(videoPath string, videoStream int, duration float64, width int, height int) (*VideoAnalysis, error) {
+	initPaths(false)
+
+	var interlacedFrames, progressiveFrames int
+	left, top, right, bottom := math.MaxInt, math.MaxInt, 0, 0
+
+	for i := 0; i < analysisWindows; i++ {
+		start := duration * float64(i+1) / float64(analysisWindows+1)
+		output, err := analyzeWindow(videoPath, videoStream, start)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range idetPattern.FindAllStringSubmatch(output, -1) {
+			tff, _ := strconv.Atoi(match[1])
+			bff, _ := strconv.Atoi(match[2])
+			progressive, _ := strconv.Atoi(match[3])
+			interlacedFrames += tff + bff
+			progressiveFrames += progressive
+		}
+
+		// cropdetect keeps growing its area over the window, so the last
+		// line covers all of it. Black windows report an empty area.
+		matches := cropdetectPattern.FindAllStringSubmatch(output, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var w, h, x, y int
+		last := matches[len(matches)-1]
+		w, _ = strconv.Atoi(last[1])
+		h, _ = strconv.Atoi(last[2])
+		x, _ = strconv.Atoi(last[3])
+		y, _ = strconv.Atoi(last[4])
+		if w <= 0 || h <= 0 {
+			continue
+		}
+		left, top = min(left, x), min(top, y)
+		right, bottom = max(right, x+w), max(bottom, y+h)
+	}
+
+	analysis := &VideoAnalysis{
+		Interlaced: interlacedFrames > progressiveFrames,
+	}
+	if right > left && bottom > top && width > 0 && height > 0 &&
+		(width-(right-left) >= minCropPixels || height-(bottom-top) >= minCropPixels) {
+		analysis.Crop = fmt.Sprintf("%d:%d:%d:%d", right-left, bottom-top, left, top)
+	}
+
+	return analysis, nil
+}
+
+// analyzeWindow decodes one window through idet and cropdetect and returns
+// ffmpeg's log
+func analyzeWindow(videoPath string, videoStream int, start float64) (string, error) {
+	cmd := exec.Command(ffmpegPath,
+		"-hide_banner", "-nostats",
+		"-ss", fmt.Sprintf("%.3f", start),
+		"-t", fmt.Sprintf("%.3f", analysisWindowDuration),
+		"-i", videoPath,
+		"-map", fmt.Sprintf("0:v:%d", videoStream),
+		"-vf", "idet,cropdetect=round=2",
+		"-an", "-sn",
+		"-f", "null", "-",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg analysis failed: %w", err)
+	}
+	return string(output), nil
+}
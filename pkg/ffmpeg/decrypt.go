@@ -0,0 +1,58 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"wails-cast/pkg/mix"
+)
+
+// DecryptSampleAES decrypts a SAMPLE-AES MPEG-TS segment. Only the media
+// samples are encrypted, so the segment is remuxed through ffmpeg's HLS
+// demuxer, which implements the sample decryption, from a one-segment
+// playlist next to the segment and key.
+func DecryptSampleAES(ctx context.Context, segment []byte, key []byte, iv []byte) ([]byte, error) {
+	if len(segment) == 0 || segment[0] != 0x47 {
+		return nil, fmt.Errorf("SAMPLE-AES is only supported for MPEG-TS segments")
+	}
+
+	dir, err := os.MkdirTemp("", "sample-aes-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "segment.ts"), segment, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "key.bin"), key, 0644); err != nil {
+		return nil, err
+	}
+	playlist := fmt.Sprintf("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:3600\n"+
+		"#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"key.bin\",IV=0x%s\n"+
+		"#EXTINF:3600,\nsegment.ts\n#EXT-X-ENDLIST\n", hex.EncodeToString(iv))
+	playlistPath := filepath.Join(dir, "playlist.m3u8")
+	if err := os.WriteFile(playlistPath, []byte(playlist), 0644); err != nil {
+		return nil, err
+	}
+
+	initPaths(false)
+	target := mix.BufferTarget()
+	args := []string{
+		"-allowed_extensions", "ALL",
+		"-protocol_whitelist", "file,crypto",
+		"-i", playlistPath,
+		"-map", "0",
+		"-c", "copy",
+		"-copyts",
+		"-f", "mpegts",
+		target.ToPipe(),
+	}
+	output, err := ffmpeg(ctx, mix.File(playlistPath), target, args)
+	if err != nil {
+		return nil, err
+	}
+	return output.Buffer, nil
+}
@@ -0,0 +1,210 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"wails-cast/pkg/logger"
+)
+
+// EncoderAuto selects the first working hardware encoder, falling back to libx264
+const EncoderAuto = "auto"
+
+// VideoCodecString is the CODECS entry of the transcoded video. Every encoder
+// profile is pinned to H.264 Main at level 4.1 so it holds whichever encoder
+// runs.
+const VideoCodecString = "avc1.4d4029"
+
+// VideoEncoder describes how to drive one H.264 encoder backend
+type VideoEncoder struct {
+	// Name is the ffmpeg encoder name passed to -c:v
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	// InputArgs are placed before -i (e.g. opening the hardware device)
+	InputArgs []string `json:"-"`
+	// OutputArgs are encoder tuning options placed after -c:v
+	OutputArgs []string `json:"-"`
+	// PixelFormat is passed as -pix_fmt; empty when frames are uploaded to
+	// the device by UploadFilter instead
+	PixelFormat string `json:"-"`
+	// UploadFilter is appended to the -vf chain to move software frames
+	// onto the encoder's device
+	UploadFilter string `json:"-"`
+}
+
+var libx264Encoder = VideoEncoder{
+	Name:        "libx264",
+	Label:       "Software (libx264)",
+	OutputArgs:  []string{"-preset", "veryfast", "-profile:v", "main", "-level:v", "4.1"},
+	PixelFormat: "yuv420p",
+}
+
+// videoEncoders lists the supported encoder profiles in auto-selection order
+var videoEncoders = []VideoEncoder{
+	{
+		Name:        "h264_videotoolbox",
+		Label:       "Apple VideoToolbox",
+		OutputArgs:  []string{"-profile:v", "main", "-level:v", "4.1"},
+		PixelFormat: "yuv420p",
+	},
+	{
+		Name:        "h264_nvenc",
+		Label:       "NVIDIA NVENC",
+		OutputArgs:  []string{"-preset", "p4", "-profile:v", "main", "-level:v", "4.1"},
+		PixelFormat: "yuv420p",
+	},
+	{
+		Name:        "h264_qsv",
+		Label:       "Intel Quick Sync",
+		OutputArgs:  []string{"-preset", "veryfast", "-profile:v", "main", "-level:v", "41"}, // numeric level_idc
+		PixelFormat: "nv12",
+	},
+	{
+		Name:         "h264_vaapi",
+		Label:        "VA-API",
+		InputArgs:    []string{"-vaapi_device", "/dev/dri/renderD128"},
+		OutputArgs:   []string{"-profile:v", "main", "-level:v", "4.1"},
+		UploadFilter: "format=nv12,hwupload",
+	},
+	libx264Encoder,
+}
+
+var (
+	encoderMu         sync.RWMutex
+	requestedEncoder  = EncoderAuto
+	activeEncoder     = libx264Encoder
+	availableEncoders []string
+)
+
+// VideoEncoders returns all supported encoder profiles
+func VideoEncoders() []VideoEncoder {
+	return videoEncoders
+}
+
+// ActiveEncoder returns the encoder currently used for transcoding
+func ActiveEncoder() VideoEncoder {
+	encoderMu.RLock()
+	defer encoderMu.RUnlock()
+	return activeEncoder
+}
+
+// AvailableEncoders returns the supported encoders found by the last probe
+func AvailableEncoders() []string {
+	encoderMu.RLock()
+	defer encoderMu.RUnlock()
+	return availableEncoders
+}
+
+// SelectEncoder probes ffmpeg and activates the requested encoder. "auto", an
+// unknown name or an encoder that fails a test encode selects the first
+// working profile in preference order, falling back to libx264.
+func SelectEncoder(name string) VideoEncoder {
+	initPaths(false)
+	if name == "" {
+		name = EncoderAuto
+	}
+
+	available := probeEncoders()
+
+	selected := libx264Encoder
+	for _, encoder := range videoEncoders {
+		if name != EncoderAuto && encoder.Name != name {
+			continue
+		}
+		if !slices.Contains(available, encoder.Name) || !testEncoder(encoder) {
+			continue
+		}
+		selected = encoder
+		break
+	}
+
+	if name != EncoderAuto && selected.Name != name {
+		logger.Logger.Warn("Requested encoder unavailable, falling back", "requested", name, "using", selected.Name)
+	} else {
+		logger.Logger.Info("Selected video encoder", "encoder", selected.Name)
+	}
+
+	encoderMu.Lock()
+	requestedEncoder = name
+	activeEncoder = selected
+	availableEncoders = available
+	encoderMu.Unlock()
+
+	return selected
+}
+
+// refreshEncoder re-runs the selection for the last requested encoder
+func refreshEncoder() VideoEncoder {
+	encoderMu.RLock()
+	name := requestedEncoder
+	encoderMu.RUnlock()
+	return SelectEncoder(name)
+}
+
+// probeEncoders returns the supported encoders compiled into ffmpeg
+func probeEncoders() []string {
+	cmd := exec.Command(ffmpegPath, "-hide_banner", "-encoders")
+	output, err := cmd.Output()
+	if err != nil {
+		logger.Logger.Warn("Failed to list ffmpeg encoders", "error", err)
+		return nil
+	}
+
+	// Lines look like " V....D h264_nvenc  NVIDIA NVENC H.264 encoder"
+	compiled := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && strings.HasPrefix(fields[0], "V") {
+			compiled[fields[1]] = true
+		}
+	}
+
+	var available []string
+	for _, encoder := range videoEncoders {
+		if compiled[encoder.Name] {
+			available = append(available, encoder.Name)
+		}
+	}
+	return available
+}
+
+// testEncoder runs a tiny encode to check the hardware behind an encoder is
+// actually usable; ffmpeg lists encoders whose devices may not be present.
+func testEncoder(encoder VideoEncoder) bool {
+	if encoder.Name == libx264Encoder.Name {
+		return true
+	}
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, encoder.InputArgs...)
+	args = append(args,
+		"-f", "lavfi",
+		"-i", "color=c=black:s=256x144:d=0.1",
+		"-frames:v", "1",
+	)
+	args = append(args, encoder.codecArgs()...)
+	if encoder.UploadFilter != "" {
+		args = append(args, "-vf", encoder.UploadFilter)
+	}
+	args = append(args, "-f", "null", "-")
+
+	if err := exec.Command(ffmpegPath, args...).Run(); err != nil {
+		logger.Logger.Debug("Encoder test failed", "encoder", encoder.Name, "error", err)
+		return false
+	}
+	return true
+}
+
+// codecArgs returns the -c:v, tuning and pixel format arguments
+func (encoder VideoEncoder) codecArgs() []string {
+	args := []string{"-c:v", encoder.Name}
+	args = append(args, encoder.OutputArgs...)
+	if encoder.PixelFormat != "" {
+		args = append(args, "-pix_fmt", encoder.PixelFormat)
+	}
+	return args
+}
@@ -8,10 +8,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"wails-cast/pkg/hls"
 	"wails-cast/pkg/logger"
 	"wails-cast/pkg/mix"
+	"wails-cast/pkg/options"
 
 	"github.com/pkg/errors"
 )
@@ -19,10 +22,33 @@ import (
 // TranscodeOptions contains options for transcoding
 type TranscodeOptions struct {
 	StartTime      float64
-	Duration       int
+	Duration       float64
 	Bitrate        string
 	MaxOutputWidth int
 	Subtitle       *SubtitleTranscodeOptions
+	// ToneMap converts HDR video to SDR; nil for SDR sources
+	ToneMap *ToneMapOptions `json:",omitempty"`
+	// CopyVideo / CopyAudio stream-copy the source instead of re-encoding
+	CopyVideo bool
+	CopyAudio bool
+	Audio     options.AudioOptions
+	Picture   options.PictureOptions
+	// Streams selects which input streams to encode; nil keeps ffmpeg's
+	// default selection (used for single-track remote segments)
+	Streams *StreamSelection `json:",omitempty"`
+	// Container is options.ContainerFMP4 for fragmented MP4 output; empty
+	// means MPEG-TS
+	Container string `json:",omitempty"`
+	// InitSegmentPath receives the fMP4 init segment (ftyp+moov) split off
+	// the transcoded output
+	InitSegmentPath string `json:"-"`
+}
+
+// StreamSelection picks the video and audio stream of a multi-track input by
+// their per-type index (-map 0:v:N / -map 0:a:N)
+type StreamSelection struct {
+	Video int
+	Audio int
 }
 
 type SubtitleTranscodeOptions struct {
@@ -30,8 +56,27 @@ type SubtitleTranscodeOptions struct {
 	FontSize int
 	Bold     bool
 	Italic   bool
+	// Source is the subtitle picked for the cast that Path was prepared from
+	Source string `json:",omitempty"`
+	// Styled keeps the positioning, fonts and effects of an ASS/SSA Path;
+	// FontSize, Bold and Italic are then only forced with ForceStyle
+	Styled     bool `json:",omitempty"`
+	ForceStyle bool `json:",omitempty"`
+	// FontsDir holds the fonts attached to the media, for libass
+	FontsDir string `json:",omitempty"`
+	// Delay is the subtitle timing offset. Styled subtitles are shifted by it
+	// when rendered; the VTT of the others already has it applied.
+	Delay float64 `json:",omitempty"`
+	// Bitmap overlays the image-based subtitle stream StreamIndex (0:s:N)
+	// of the input instead of rendering Path
+	Bitmap      bool `json:",omitempty"`
+	StreamIndex int  `json:",omitempty"`
 }
 
+// bitmapSubtitleLookback is how far before a segment the bitmap subtitle
+// input is opened, so a subtitle already on screen at the cut is kept
+const bitmapSubtitleLookback = 10.0
+
 // TranscodeSegment transcodes a segment with optional 100ms wait to avoid wasted work during rapid seeking
 func TranscodeSegment(ctx context.Context, input *mix.FileOrBuffer, target *mix.TargetFileOrBuffer, opts *TranscodeOptions) (*mix.FileOrBuffer, error) {
 	// Build ffmpeg arguments
@@ -40,10 +85,21 @@ func TranscodeSegment(ctx context.Context, input *mix.FileOrBuffer, target *mix.
 		return nil, err
 	}
 
+	// Segment transcodes share the process cap, see SetMaxProcesses
+	release, err := acquireProcess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// log the call
 	fmt.Printf(">>>> ffmpeg %s\n\n", strings.Join(args, " "))
 	initPaths(false)
-	return ffmpeg(ctx, input, target, args)
+	output, err := ffmpeg(ctx, input, target, args)
+	if err != nil || opts.Container != options.ContainerFMP4 {
+		return output, err
+	}
+	return splitInitSegment(output, opts.InitSegmentPath)
 }
 
 func ffmpeg(ctx context.Context, input *mix.FileOrBuffer, output *mix.TargetFileOrBuffer, args []string) (*mix.FileOrBuffer, error) {
@@ -77,30 +133,101 @@ func ffmpeg(ctx context.Context, input *mix.FileOrBuffer, output *mix.TargetFile
 func buildTranscodeArgs(input *mix.FileOrBuffer, output *mix.TargetFileOrBuffer, opts *TranscodeOptions) ([]string, error) {
 	args := []string{"-y"}
 
-	// Local playback transcodes fixed-duration segments on demand (Duration > 0),
-	// so each segment is an independent encode that must be placed on an exact
-	// timeline grid. Remote playback proxies whole source segments (Duration == 0)
-	// and must preserve their original timestamps.
+	// Local playback transcodes keyframe-aligned segments on demand
+	// (Duration > 0), so each segment is an independent encode that must be
+	// placed on an exact timeline position. Remote playback proxies whole
+	// source segments (Duration == 0) and must preserve their original
+	// timestamps.
 	segmented := opts.Duration > 0
 
+	// Full precision so seeking lands exactly on the segment's keyframe
+	// rather than the one before it
 	if opts.StartTime > 0 {
-		args = append(args, "-ss", fmt.Sprintf("%.2f", opts.StartTime))
+		args = append(args, "-ss", fmt.Sprintf("%.6f", opts.StartTime))
 	}
 	if opts.Duration > 0 {
-		args = append(args, "-t", fmt.Sprintf("%d", opts.Duration))
+		args = append(args, "-t", fmt.Sprintf("%.6f", opts.Duration))
+	}
+
+	encoder := ActiveEncoder()
+	if !opts.CopyVideo {
+		args = append(args, encoder.InputArgs...)
+		if opts.ToneMap != nil {
+			args = append(args, opts.ToneMap.inputArgs()...)
+		}
 	}
 
 	// Input file
 	args = append(args, "-i", input.ToPipe())
 
-	args = append(args,
-		"-c:v", "h264_videotoolbox",
-		"-pix_fmt", "yuv420p",
-		"-c:a", "aac",
-		"-b:a", "96k",
-		"-ac", "2",
-		"-f", "mpegts",
-	)
+	// Bitmap subtitles are read from the same file opened a second time a
+	// little earlier, see buildOverlayGraph
+	bitmapSubtitle := opts.Subtitle != nil && opts.Subtitle.Bitmap && !opts.CopyVideo
+	subtitleOffset := 0.0
+	if bitmapSubtitle {
+		subtitleStart := max(0, opts.StartTime-bitmapSubtitleLookback)
+		subtitleOffset = opts.StartTime - subtitleStart
+		if subtitleStart > 0 {
+			args = append(args, "-ss", fmt.Sprintf("%.6f", subtitleStart))
+		}
+		if opts.Duration > 0 {
+			args = append(args, "-t", fmt.Sprintf("%.6f", opts.Duration+subtitleOffset))
+		}
+		args = append(args, "-i", input.ToPipe())
+	}
+
+	videoMap := "0:v:0"
+	audioMap := "0:a?"
+	if opts.Streams != nil {
+		videoMap = fmt.Sprintf("0:v:%d", opts.Streams.Video)
+		// The audio map is optional so files without audio still transcode
+		audioMap = fmt.Sprintf("0:a:%d?", opts.Streams.Audio)
+	}
+	if bitmapSubtitle {
+		args = append(args, "-map", "[v]", "-map", audioMap)
+	} else if opts.Streams != nil {
+		args = append(args, "-map", videoMap, "-map", audioMap)
+	}
+
+	if opts.CopyVideo {
+		args = append(args, "-c:v", "copy")
+	} else {
+		args = append(args, encoder.codecArgs()...)
+	}
+
+	if opts.CopyAudio {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-c:a", opts.Audio.Codec())
+		switch {
+		case !opts.Audio.Surround():
+			audioBitrate := opts.Audio.Bitrate
+			if audioBitrate == "" {
+				audioBitrate = "96k"
+			}
+			args = append(args, "-b:a", audioBitrate, "-ac", "2")
+		case opts.Audio.Codec() == "ac3":
+			args = append(args, "-b:a", "448k")
+		default:
+			args = append(args, "-b:a", "384k")
+		}
+		if filter := buildAudioFilter(opts.Audio); filter != "" {
+			args = append(args, "-af", filter)
+		}
+	}
+
+	if opts.Container == options.ContainerFMP4 {
+		// Every ffmpeg run writes its own moov; they are identical for the
+		// same options, so one is kept as the shared init segment.
+		// frag_discont makes tfdt carry the real decode time instead of 0.
+		args = append(args,
+			"-f", "mp4",
+			"-movflags", "+frag_keyframe+empty_moov+default_base_moof+frag_discont",
+			"-video_track_timescale", "90000",
+		)
+	} else {
+		args = append(args, "-f", "mpegts")
+	}
 
 	if segmented {
 		// Reset each segment's timestamps to a local zero and re-anchor it at its
@@ -111,7 +238,7 @@ func buildTranscodeArgs(input *mix.FileOrBuffer, output *mix.TargetFileOrBuffer,
 		// desync over time. Input seeking stays frame-accurate via -accurate_seek
 		// (default), so this does not hurt transcoding performance.
 		args = append(args,
-			"-output_ts_offset", fmt.Sprintf("%.2f", opts.StartTime),
+			"-output_ts_offset", fmt.Sprintf("%.6f", opts.StartTime),
 			"-muxpreload", "0",
 			"-muxdelay", "0",
 		)
@@ -120,38 +247,149 @@ func buildTranscodeArgs(input *mix.FileOrBuffer, output *mix.TargetFileOrBuffer,
 		args = append(args, "-copyts")
 	}
 
+	// A stream-copied video track cannot take encoder options or filters
+	if opts.CopyVideo {
+		return append(args, output.ToPipe()), nil
+	}
+
 	if opts.Bitrate != "" {
 		args = append(args, "-b:v", opts.Bitrate)
 	}
 
-	var filterStr []string
+	// Deinterlacing and tone mapping run on the full-resolution source
+	// frames, before bitmap subtitles are overlaid
+	var sourceFilters, filterStr []string
+
+	if filter := opts.Picture.DeinterlaceFilter(); filter != "" {
+		sourceFilters = append(sourceFilters, filter)
+	}
+
+	if opts.ToneMap != nil {
+		if filter := opts.ToneMap.filter(); filter != "" {
+			sourceFilters = append(sourceFilters, filter)
+		}
+	}
+
+	picture := opts.Picture
+	if bitmapSubtitle {
+		// Bitmap subtitles are often placed in the black bars
+		picture.Crop = ""
+	}
+	filterStr = append(filterStr, buildPictureFilters(picture)...)
 
 	if opts.MaxOutputWidth > 0 {
 		filterStr = append(filterStr, fmt.Sprintf("scale='min(%d,iw)':'-2':'force_original_aspect_ratio=decrease'", opts.MaxOutputWidth))
 	}
 
-	if opts.Subtitle != nil {
+	if opts.Subtitle != nil && !bitmapSubtitle {
 		filterStr = append(filterStr, buildSubtitleFilter(opts.Subtitle))
 	}
 
-	if len(filterStr) > 0 {
-		args = append(args, "-vf", strings.Join(filterStr, ","))
+	if encoder.UploadFilter != "" {
+		filterStr = append(filterStr, encoder.UploadFilter)
+	}
+
+	if bitmapSubtitle {
+		args = append(args, "-filter_complex", buildOverlayGraph(videoMap, opts.Subtitle.StreamIndex, subtitleOffset, sourceFilters, filterStr))
+	} else if filters := append(sourceFilters, filterStr...); len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
 	}
 
 	// Output file
 	return append(args, output.ToPipe()), nil
 }
 
+// buildOverlayGraph builds the -filter_complex graph burning in a bitmap
+// subtitle stream of the second input. That input starts offset seconds
+// earlier, so its timestamps are shifted back onto the video's timeline. The
+// subtitle canvas is scaled to the video in case their sizes differ.
+func buildOverlayGraph(videoMap string, subtitleIndex int, offset float64, sourceFilters []string, filters []string) string {
+	video := "null"
+	if len(sourceFilters) > 0 {
+		video = strings.Join(sourceFilters, ",")
+	}
+	output := "[base][sub]overlay=eof_action=pass"
+	if len(filters) > 0 {
+		output += "," + strings.Join(filters, ",")
+	}
+	return fmt.Sprintf("[%s]%s[source];[1:s:%d]setpts=PTS-%f/TB[canvas];[canvas][source]scale2ref[sub][base];%s[v]",
+		videoMap, video, subtitleIndex, offset, output)
+}
+
+// buildPictureFilters returns the crop, zoom and aspect ratio filters. The
+// forced aspect ratio is applied by resampling to square pixels, which every
+// receiver displays correctly.
+func buildPictureFilters(picture options.PictureOptions) []string {
+	var filters []string
+
+	if crop := picture.CropArea(); crop != "" {
+		filters = append(filters, "crop="+crop)
+	}
+
+	if picture.Zoomed() {
+		filters = append(filters, fmt.Sprintf("crop=trunc(iw/%[1]g/2)*2:trunc(ih/%[1]g/2)*2", picture.Zoom))
+	}
+
+	if width, height, ok := picture.Aspect(); ok {
+		filters = append(filters, fmt.Sprintf("scale=trunc(ih*%d/%d/2)*2:ih,setsar=1", width, height))
+	}
+
+	return filters
+}
+
+// buildAudioFilter builds the -af chain for the audio processing options
+func buildAudioFilter(audio options.AudioOptions) string {
+	var filters []string
+
+	if audio.DialogueBoost && !audio.Surround() {
+		// Downmix with the center (dialogue) channel at +3dB instead of the
+		// default -3dB and the surrounds lowered; a no-op for stereo sources
+		filters = append(filters, "aresample=out_chlayout=stereo:center_mix_level=1.414:surround_mix_level=0.5")
+	}
+
+	if audio.NightMode {
+		filters = append(filters, "acompressor=threshold=0.1:ratio=4:attack=20:release=250:makeup=2")
+	}
+
+	if audio.Loudnorm {
+		// Single-pass (dynamic) loudnorm works per segment; it upsamples to
+		// 192kHz, so resample back for AAC
+		filters = append(filters, "loudnorm=I=-16:TP=-1.5:LRA=11", "aresample=48000")
+	}
+
+	if audio.Surround() {
+		// Keep up to 5.1 channels; 7.1 is folded down and stereo stays stereo
+		filters = append(filters, "aformat=channel_layouts=5.1|stereo|mono")
+	}
+
+	return strings.Join(filters, ",")
+}
+
 // buildSubtitleFilter builds the subtitle filter string for ffmpeg
 func buildSubtitleFilter(subtitle *SubtitleTranscodeOptions) string {
-	style := fmt.Sprintf("FontSize=%d", subtitle.FontSize)
-	if subtitle.Bold {
-		style += ",Bold=1"
+	filter := fmt.Sprintf("subtitles='%s'", subtitle.Path)
+	if subtitle.FontsDir != "" {
+		filter += fmt.Sprintf(":fontsdir='%s'", subtitle.FontsDir)
 	}
-	if subtitle.Italic {
-		style += ",Italic=1"
+
+	if !subtitle.Styled || subtitle.ForceStyle {
+		style := fmt.Sprintf("FontSize=%d", subtitle.FontSize)
+		if subtitle.Bold {
+			style += ",Bold=1"
+		}
+		if subtitle.Italic {
+			style += ",Italic=1"
+		}
+		filter += fmt.Sprintf(":force_style='%s'", style)
 	}
-	return fmt.Sprintf("subtitles='%s':force_style='%s'", subtitle.Path, style)
+
+	// Render against timestamps shifted back by the delay so the subtitles
+	// appear that much later, then restore the original timestamps
+	if subtitle.Styled && subtitle.Delay != 0 {
+		filter = fmt.Sprintf("setpts=PTS-%[1]f/TB,%[2]s,setpts=PTS+%[1]f/TB", subtitle.Delay, filter)
+	}
+
+	return filter
 }
 
 // GetVideoDuration gets the duration of a video file using ffprobe
@@ -180,6 +418,49 @@ func GetVideoDuration(videoPath string) (float64, error) {
 	return duration, nil
 }
 
+// GetKeyframes returns the times of the keyframes of a video stream relative
+// to the start of the file (as used by -ss). They are read from packet flags
+// so nothing has to be decoded.
+func GetKeyframes(videoPath string, videoStream int) ([]float64, error) {
+	initPaths(false)
+	cmd := exec.Command(ffprobePath,
+		"-v", "error",
+		"-select_streams", fmt.Sprintf("v:%d", videoStream),
+		"-show_entries", "packet=pts_time,flags:format=start_time",
+		"-of", "csv=p=0",
+		videoPath,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var keyframes []float64
+	startTime := 0.0
+	for _, line := range strings.Split(string(output), "\n") {
+		// Packet lines look like "12.345000,K__", the format line is just
+		// the container start time
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) == 1 {
+			startTime = value
+		} else if strings.HasPrefix(fields[1], "K") {
+			keyframes = append(keyframes, value)
+		}
+	}
+
+	for i := range keyframes {
+		keyframes[i] -= startTime
+	}
+	sort.Float64s(keyframes)
+
+	return keyframes, nil
+}
+
 func ExportEmbeddedSubtitles(videoPath string) error {
 	if strings.HasPrefix(videoPath, "http://") || strings.HasPrefix(videoPath, "https://") {
 		return fmt.Errorf("cannot export subtitles from remote URLs")
@@ -204,6 +485,11 @@ func ExportEmbeddedSubtitles(videoPath string) error {
 	}
 
 	for _, sub := range trackInfo.SubtitleTracks {
+		if IsBitmapSubtitle(sub.Codec) {
+			logger.Logger.Info("Skipping image-based subtitle", "index", sub.Index, "codec", sub.Codec)
+			continue
+		}
+
 		name := sub.Name
 		if name == "" {
 			name = sub.Language
@@ -236,12 +522,75 @@ func ExtractSubtitle(videoPath string, subIndex int, target *mix.TargetFileOrBuf
 	return ffmpeg(context.Background(), mix.File(videoPath), target, args)
 }
 
+// IsBitmapSubtitle reports whether a subtitle codec is image-based (PGS,
+// VobSub, DVB), which cannot be converted to text and must be overlaid
+func IsBitmapSubtitle(codec string) bool {
+	switch codec {
+	case "hdmv_pgs_subtitle", "dvd_subtitle", "dvb_subtitle", "xsub":
+		return true
+	}
+	return false
+}
+
+// IsStyledSubtitle reports whether a subtitle codec carries ASS/SSA styling
+// that is lost when converting to WebVTT
+func IsStyledSubtitle(codec string) bool {
+	return codec == "ass" || codec == "ssa"
+}
+
+// ExtractStyledSubtitle copies an embedded ASS/SSA subtitle stream as is, so
+// libass can render its positioning, fonts and effects
+func ExtractStyledSubtitle(videoPath string, subIndex int, target *mix.TargetFileOrBuffer) (*mix.FileOrBuffer, error) {
+	args := []string{
+		"-i", videoPath,
+		"-map", fmt.Sprintf("0:s:%d", subIndex),
+		"-c:s", "copy",
+		"-f", "ass",
+		"-y",
+		target.ToPipe(),
+	}
+	return ffmpeg(context.Background(), mix.File(videoPath), target, args)
+}
+
+// ExtractFontAttachments dumps the fonts attached to a media file (common in
+// MKVs with ASS subtitles) into fontsDir, replacing what it held, and returns
+// how many files were written
+func ExtractFontAttachments(videoPath string, fontsDir string) (int, error) {
+	initPaths(false)
+
+	// Only the fonts of this run are counted
+	if err := os.RemoveAll(fontsDir); err != nil {
+		return 0, errors.Wrap(err, "failed to clear fonts folder")
+	}
+	if err := os.MkdirAll(fontsDir, 0755); err != nil {
+		return 0, errors.Wrap(err, "failed to create fonts folder")
+	}
+
+	// Attachments are written relative to the working directory. ffmpeg
+	// always exits with an error since there is no output file, so any other
+	// error is reported.
+	var stderr bytes.Buffer
+	cmd := exec.Command(ffmpegPath, "-hide_banner", "-y", "-dump_attachment:t", "", "-i", videoPath)
+	cmd.Dir = fontsDir
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	entries, err := os.ReadDir(fontsDir)
+	if err != nil {
+		return 0, err
+	}
+	if runErr != nil && !strings.Contains(stderr.String(), "At least one output file must be specified") {
+		return len(entries), errors.Wrapf(runErr, "%s", strings.TrimSpace(stderr.String()))
+	}
+	return len(entries), nil
+}
+
 // GetMediaTrackInfo gets all track information for a media file using ffprobe
 func GetMediaTrackInfo(mediaPath string) (*hls.ManifestPlaylist, error) {
 	initPaths(false)
 	cmd := exec.Command(ffprobePath,
 		"-v", "error",
-		"-show_entries", "stream=index,codec_type,codec_name,width,height:stream_tags=language,title",
+		"-show_entries", "stream=index,codec_type,codec_name,profile,level,pix_fmt,bits_per_raw_sample,color_transfer,color_primaries,channels,bit_rate,width,height:stream_tags=language,title:format=bit_rate",
 		"-of", "json",
 		mediaPath,
 	)
@@ -253,16 +602,27 @@ func GetMediaTrackInfo(mediaPath string) (*hls.ManifestPlaylist, error) {
 
 	var result struct {
 		Streams []struct {
-			Index     int    `json:"index"`
-			CodecType string `json:"codec_type"`
-			CodecName string `json:"codec_name"`
-			Width     int    `json:"width"`
-			Height    int    `json:"height"`
-			Tags      struct {
+			Index            int    `json:"index"`
+			CodecType        string `json:"codec_type"`
+			CodecName        string `json:"codec_name"`
+			Profile          string `json:"profile"`
+			Level            int    `json:"level"`
+			PixFmt           string `json:"pix_fmt"`
+			BitsPerRawSample string `json:"bits_per_raw_sample"`
+			ColorTransfer    string `json:"color_transfer"`
+			ColorPrimaries   string `json:"color_primaries"`
+			Channels         int    `json:"channels"`
+			BitRate          string `json:"bit_rate"`
+			Width            int    `json:"width"`
+			Height           int    `json:"height"`
+			Tags             struct {
 				Language string `json:"language"`
 				Title    string `json:"title"`
 			} `json:"tags"`
 		} `json:"streams"`
+		Format struct {
+			BitRate string `json:"bit_rate"`
+		} `json:"format"`
 	}
 
 	if err := json.Unmarshal(output, &result); err != nil {
@@ -286,16 +646,34 @@ func GetMediaTrackInfo(mediaPath string) (*hls.ManifestPlaylist, error) {
 			if stream.Width > 0 && stream.Height > 0 {
 				resolution = fmt.Sprintf("%dx%d", stream.Width, stream.Height)
 			}
+			// Containers like MKV often only report the overall bitrate
+			bitrate, err := strconv.Atoi(stream.BitRate)
+			if err != nil {
+				bitrate, _ = strconv.Atoi(result.Format.BitRate)
+			}
 			info.VideoTracks = append(info.VideoTracks, hls.VideoTrack{
 				Index:      videoIdx,
 				Codecs:     stream.CodecName,
 				Resolution: resolution,
+				Bandwidth:  bitrate,
+				Profile:    stream.Profile,
+				Level:      stream.Level,
+				BitDepth:   bitDepth(stream.BitsPerRawSample, stream.PixFmt),
+
+				ColorTransfer:  stream.ColorTransfer,
+				ColorPrimaries: stream.ColorPrimaries,
 			})
 			videoIdx++
 		case "audio":
+			channels := ""
+			if stream.Channels > 0 {
+				channels = strconv.Itoa(stream.Channels)
+			}
 			info.AudioTracks = append(info.AudioTracks, hls.AudioTrack{
 				Index:    audioIdx,
 				Language: stream.Tags.Language,
+				Codec:    stream.CodecName,
+				Channels: channels,
 			})
 			audioIdx++
 		case "subtitle":
@@ -303,6 +681,7 @@ func GetMediaTrackInfo(mediaPath string) (*hls.ManifestPlaylist, error) {
 				Index:    subtitleIdx,
 				Language: stream.Tags.Language,
 				Name:     stream.Tags.Title,
+				Codec:    stream.CodecName,
 			})
 			subtitleIdx++
 		}
@@ -310,3 +689,18 @@ func GetMediaTrackInfo(mediaPath string) (*hls.ManifestPlaylist, error) {
 
 	return info, nil
 }
+
+// bitDepth returns the video bit depth, falling back to the pixel format
+// when ffprobe does not report bits_per_raw_sample
+func bitDepth(bitsPerRawSample string, pixFmt string) int {
+	if depth, err := strconv.Atoi(bitsPerRawSample); err == nil && depth > 0 {
+		return depth
+	}
+	switch {
+	case strings.Contains(pixFmt, "12"):
+		return 12
+	case strings.Contains(pixFmt, "10"):
+		return 10
+	}
+	return 8
+}
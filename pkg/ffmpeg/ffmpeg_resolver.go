@@ -21,6 +21,10 @@ type FFmpegInfo struct {
 	FFprobeVersion   string `json:"ffprobeVersion"`
 	FFmpegPath       string `json:"ffmpegPath"`
 	FFprobePath      string `json:"ffprobePath"`
+	// VideoEncoder is the encoder selected for transcoding
+	VideoEncoder string `json:"videoEncoder"`
+	// AvailableEncoders lists the supported encoders found in this ffmpeg build
+	AvailableEncoders []string `json:"availableEncoders"`
 }
 
 // initPaths initializes the ffmpeg and ffprobe paths
@@ -51,6 +55,12 @@ func GetFFmpegInfo(searchAgain bool) (*FFmpegInfo, error) {
 		}
 	}
 
+	if searchAgain {
+		refreshEncoder()
+	}
+	info.VideoEncoder = ActiveEncoder().Name
+	info.AvailableEncoders = AvailableEncoders()
+
 	// Check ffprobe
 	if _, err := os.Stat(ffprobePath); err == nil {
 		info.FFprobeInstalled = true
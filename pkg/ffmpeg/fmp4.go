@@ -0,0 +1,80 @@
+package ffmpeg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wails-cast/pkg/mix"
+)
+
+// splitInitSegment moves the ftyp and moov boxes of a fragmented MP4 into
+// initPath and leaves only the media fragments (moof/mdat) in the output
+func splitInitSegment(output *mix.FileOrBuffer, initPath string) (*mix.FileOrBuffer, error) {
+	data := output.Buffer
+	if !output.IsBuffer {
+		var err error
+		data, err = os.ReadFile(output.FilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var initSegment, media []byte
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 8 {
+			return nil, fmt.Errorf("truncated mp4 box at offset %d", offset)
+		}
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		boxType := string(data[offset+4 : offset+8])
+		switch size {
+		case 0:
+			// Box extends to the end of the file
+			size = len(data) - offset
+		case 1:
+			if len(data)-offset < 16 {
+				return nil, fmt.Errorf("truncated mp4 box at offset %d", offset)
+			}
+			size = int(binary.BigEndian.Uint64(data[offset+8:]))
+		}
+		if size < 8 || offset+size > len(data) {
+			return nil, fmt.Errorf("invalid mp4 box %q at offset %d", boxType, offset)
+		}
+
+		box := data[offset : offset+size]
+		if boxType == "ftyp" || boxType == "moov" {
+			initSegment = append(initSegment, box...)
+		} else {
+			media = append(media, box...)
+		}
+		offset += size
+	}
+
+	if len(initSegment) == 0 {
+		return nil, fmt.Errorf("no init segment in fragmented mp4 output")
+	}
+
+	// Write through a temporary file so a receiver never reads a partial init
+	temp, err := os.CreateTemp(filepath.Dir(initPath), "init_*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	_, err = temp.Write(initSegment)
+	temp.Close()
+	if err == nil {
+		err = os.Rename(temp.Name(), initPath)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return nil, err
+	}
+
+	if output.IsBuffer {
+		return mix.Buffer(media), nil
+	}
+	if err := os.WriteFile(output.FilePath, media, 0644); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -28,24 +28,53 @@ func LoadSegmentManifest(manifestPath string) (*TranscodeOptions, error) {
 	return &manifest, nil
 }
 
-func (manifest *SubtitleTranscodeOptions) Matches(options options.SubtitleCastOptions) bool {
-	return manifest.Path == options.Path &&
-		manifest.FontSize == options.FontSize
+// Matches reports whether the subtitle burned in with manifest is the one
+// options picks, rendered as styled when styled is set
+func (manifest *SubtitleTranscodeOptions) Matches(options options.SubtitleCastOptions, styled bool) bool {
+	return manifest.Source == options.Path &&
+		manifest.Styled == styled &&
+		manifest.FontSize == options.FontSize &&
+		manifest.Bold == options.Bold &&
+		manifest.Italic == options.Italic &&
+		manifest.ForceStyle == options.ForceStyle &&
+		manifest.Delay == options.DelaySeconds
 }
 
-// ManifestMatches checks if current parameters match manifest (used by local file HLS)
-func ManifestMatches(manifest *TranscodeOptions, options options.StreamOptions, duration int) bool {
+// ManifestMatches checks if current parameters match manifest (used by local file HLS).
+// styledSubtitle tells whether a burned in subtitle keeps its ASS/SSA styles.
+func ManifestMatches(manifest *TranscodeOptions, options options.StreamOptions, duration float64, streams *StreamSelection, styledSubtitle bool) bool {
 	if manifest == nil {
 		return false
 	}
 
-	if options.Subtitle.BurnIn {
-		if !manifest.Subtitle.Matches(options.Subtitle) {
+	if (manifest.Streams == nil) != (streams == nil) ||
+		streams != nil && *manifest.Streams != *streams {
+		return false
+	}
+
+	if options.Subtitle.BurnsIn() {
+		if manifest.Subtitle == nil || !manifest.Subtitle.Matches(options.Subtitle, styledSubtitle) {
 			return false
 		}
 	}
 
-	if !options.Subtitle.BurnIn && manifest.Subtitle != nil {
+	if !options.Subtitle.BurnsIn() && manifest.Subtitle != nil {
+		return false
+	}
+
+	if manifest.CopyVideo != options.VideoCopied() || manifest.CopyAudio != options.AudioCopied() {
+		return false
+	}
+
+	if (manifest.ToneMap != nil) != options.ToneMapped() {
+		return false
+	}
+
+	if manifest.Picture != options.Picture {
+		return false
+	}
+
+	if manifest.Audio != options.Audio {
 		return false
 	}
 
@@ -61,5 +90,9 @@ func ManifestMatches(manifest *TranscodeOptions, options options.StreamOptions,
 		return false
 	}
 
+	if (manifest.Container != "") != options.FragmentedMP4() {
+		return false
+	}
+
 	return true
 }
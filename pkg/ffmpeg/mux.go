@@ -0,0 +1,186 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MuxOptions describes the export of downloaded segments into one file
+type MuxOptions struct {
+	// VideoSegments and AudioSegments are the segment files in playback
+	// order; AudioSegments is empty when the video segments carry the audio
+	VideoSegments []string
+	AudioSegments []string
+	// Duration is the length of the media, used to report progress
+	Duration float64
+	// Output is the .mp4 or .mkv file to write
+	Output string
+	Title  string
+	// Subtitle is muxed in as a soft subtitle track (a VTT or ASS file),
+	// unless BurnIn is set, in which case the video is re-encoded with it rendered
+	Subtitle         string
+	SubtitleLanguage string
+	// SubtitleDelay shifts a soft subtitle file that is not rewritten, such
+	// as an ASS file
+	SubtitleDelay float64
+	BurnIn        *SubtitleTranscodeOptions
+}
+
+// Mux concatenates downloaded segments into a single MP4 or MKV file.
+// progress is called with the completed fraction while ffmpeg runs. An
+// existing output is never overwritten, and a canceled context removes the
+// partial output.
+func Mux(ctx context.Context, opts *MuxOptions, progress func(float64)) error {
+	if len(opts.VideoSegments) == 0 {
+		return fmt.Errorf("no video segments to export")
+	}
+	listDir, err := os.MkdirTemp("", "wails-cast-mux")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(listDir)
+
+	// The output is created here, so an existing file fails instead of
+	// being replaced; ffmpeg then overwrites the empty placeholder
+	placeholder, err := os.OpenFile(opts.Output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	placeholder.Close()
+	output := opts.Output
+	defer func() {
+		if output != "" {
+			os.Remove(output)
+		}
+	}()
+
+	encoder := ActiveEncoder()
+	args := []string{"-y", "-nostats", "-progress", "pipe:1"}
+	if opts.BurnIn != nil {
+		args = append(args, encoder.InputArgs...)
+	}
+	videoList, err := writeConcatList(filepath.Join(listDir, "video.txt"), opts.VideoSegments)
+	if err != nil {
+		return err
+	}
+	args = append(args, "-f", "concat", "-safe", "0", "-i", videoList)
+	audioInput := "0:a:0?"
+	inputs := 1
+	if len(opts.AudioSegments) > 0 {
+		audioList, err := writeConcatList(filepath.Join(listDir, "audio.txt"), opts.AudioSegments)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-f", "concat", "-safe", "0", "-i", audioList)
+		audioInput = "1:a:0"
+		inputs++
+	}
+	softSubtitle := opts.Subtitle != "" && opts.BurnIn == nil
+	if softSubtitle {
+		if opts.SubtitleDelay != 0 {
+			args = append(args, "-itsoffset", fmt.Sprintf("%.3f", opts.SubtitleDelay))
+		}
+		args = append(args, "-i", opts.Subtitle)
+	}
+
+	args = append(args, "-map", "0:v:0", "-map", audioInput)
+	if softSubtitle {
+		args = append(args, "-map", fmt.Sprintf("%d:s:0", inputs))
+	}
+
+	if opts.BurnIn != nil {
+		args = append(args, encoder.codecArgs()...)
+		filters := []string{buildSubtitleFilter(opts.BurnIn)}
+		if encoder.UploadFilter != "" {
+			filters = append(filters, encoder.UploadFilter)
+		}
+		args = append(args, "-vf", strings.Join(filters, ","))
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+	args = append(args, "-c:a", "copy")
+
+	// The MP4 muxer converts the ADTS AAC of MPEG-TS segments by itself,
+	// and leaves the other audio codecs alone
+	mp4 := strings.EqualFold(filepath.Ext(opts.Output), ".mp4")
+	if mp4 {
+		args = append(args, "-movflags", "+faststart")
+	}
+	if softSubtitle {
+		// MKV keeps VTT and ASS subtitles as they are, styles included; MP4
+		// only takes mov_text
+		if mp4 {
+			args = append(args, "-c:s", "mov_text")
+		} else {
+			args = append(args, "-c:s", "copy")
+		}
+		if opts.SubtitleLanguage != "" {
+			args = append(args, "-metadata:s:s:0", "language="+opts.SubtitleLanguage)
+		}
+	}
+	if opts.Title != "" {
+		args = append(args, "-metadata", "title="+opts.Title)
+	}
+	args = append(args, opts.Output)
+
+	release, err := acquireProcess(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	initPaths(false)
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "failed to start ffmpeg")
+	}
+
+	// -progress reports key=value lines; out_time_us is the muxed position
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if !found || opts.Duration <= 0 || progress == nil {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+			progress(min(float64(us)/1e6/opts.Duration, 1))
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.Wrapf(err, "%s", stderr.String())
+	}
+	output = ""
+	return nil
+}
+
+// writeConcatList writes an input list of the concat demuxer
+func writeConcatList(path string, files []string) (string, error) {
+	var list strings.Builder
+	for _, file := range files {
+		// Single quotes are escaped by closing, escaping and reopening
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(file, "'", `'\''`))
+	}
+	if err := os.WriteFile(path, []byte(list.String()), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
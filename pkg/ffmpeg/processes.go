@@ -0,0 +1,160 @@
+package ffmpeg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// processMu guards the transcode slots below
+	processMu sync.Mutex
+	// maxProcesses bounds concurrent transcodes; 0 means unlimited
+	maxProcesses     int
+	runningProcesses int
+	// waitingProcesses counts the transcodes waiting for a slot that
+	// playback waits for. Prefetches let them go first.
+	waitingProcesses int
+	// processChanged is closed and replaced whenever a slot may have become
+	// available
+	processChanged = make(chan struct{})
+)
+
+// SetMaxProcesses caps the number of ffmpeg transcodes running at once
+// (0 = unlimited). Transcodes already running keep their slot.
+func SetMaxProcesses(max int) {
+	processMu.Lock()
+	defer processMu.Unlock()
+	maxProcesses = max
+	notifyProcessChanged()
+}
+
+type priorityKey struct{}
+
+// Priority tells whether playback waits for a transcode. A transcode shared
+// by several requests starts with the priority of the first one and is
+// raised once playback joins it.
+type Priority struct {
+	prefetch atomic.Bool
+	// waiting counts the transcodes of this priority waiting for a slot as
+	// prefetches; processMu must be held
+	waiting int
+}
+
+// NewPriority returns the priority of a transcode started for ctx
+func NewPriority(ctx context.Context) *Priority {
+	priority := &Priority{}
+	priority.prefetch.Store(isPrefetch(ctx))
+	return priority
+}
+
+// Join raises the priority when playback waits for ctx, letting a transcode
+// started by a prefetch go before the other prefetches
+func (p *Priority) Join(ctx context.Context) {
+	if isPrefetch(ctx) {
+		return
+	}
+	processMu.Lock()
+	defer processMu.Unlock()
+	if !p.prefetch.Swap(false) {
+		return
+	}
+	// Its waiting transcodes now keep the prefetches waiting
+	waitingProcesses += p.waiting
+	p.waiting = 0
+	notifyProcessChanged()
+}
+
+// WithPriority makes the transcodes run for ctx follow priority, whatever
+// ctx was marked with before
+func WithPriority(ctx context.Context, priority *Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// WithPrefetch marks transcodes that playback does not wait for. They only
+// get a slot when no other transcode is waiting for one.
+func WithPrefetch(ctx context.Context) context.Context {
+	priority := &Priority{}
+	priority.prefetch.Store(true)
+	return WithPriority(ctx, priority)
+}
+
+func isPrefetch(ctx context.Context) bool {
+	priority, _ := ctx.Value(priorityKey{}).(*Priority)
+	return priority != nil && priority.prefetch.Load()
+}
+
+// acquireProcess waits for a free transcode slot. The returned function
+// releases it. The priority is read again on every wake up, since playback
+// may join a prefetch while it waits.
+func acquireProcess(ctx context.Context) (func(), error) {
+	priority, _ := ctx.Value(priorityKey{}).(*Priority)
+	processMu.Lock()
+	// waiting is set once counted in waitingProcesses, parked while counted
+	// in priority.waiting
+	waiting, parked := false, false
+	for {
+		if parked && !priority.prefetch.Load() {
+			// Join moved it to waitingProcesses
+			parked, waiting = false, true
+		}
+		prefetch := priority != nil && priority.prefetch.Load()
+		if !prefetch && !waiting {
+			waitingProcesses++
+			waiting = true
+		}
+		if maxProcesses <= 0 || runningProcesses < maxProcesses && (!prefetch || waitingProcesses == 0) {
+			break
+		}
+		if prefetch && !parked {
+			priority.waiting++
+			parked = true
+		}
+		changed := processChanged
+		processMu.Unlock()
+		select {
+		case <-changed:
+			processMu.Lock()
+		case <-ctx.Done():
+			processMu.Lock()
+			if parked && !priority.prefetch.Load() {
+				parked, waiting = false, true
+			}
+			if parked {
+				priority.waiting--
+			}
+			if waiting {
+				waitingProcesses--
+				notifyProcessChanged()
+			}
+			processMu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	if parked {
+		priority.waiting--
+	}
+	if waiting {
+		waitingProcesses--
+		notifyProcessChanged()
+	}
+	runningProcesses++
+	processMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			processMu.Lock()
+			defer processMu.Unlock()
+			runningProcesses--
+			notifyProcessChanged()
+		})
+	}, nil
+}
+
+// notifyProcessChanged wakes up the transcodes waiting for a slot;
+// processMu must be held
+func notifyProcessChanged() {
+	close(processChanged)
+	processChanged = make(chan struct{})
+}
@@ -0,0 +1,105 @@
+package ffmpeg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireProcessOrder(t *testing.T) {
+	playback := func() context.Context { return context.Background() }
+	prefetch := func() context.Context { return WithPrefetch(context.Background()) }
+	// joined is a prefetch that playback joins while it waits
+	joined := func() context.Context {
+		return WithPriority(context.Background(), NewPriority(WithPrefetch(context.Background())))
+	}
+
+	tests := []struct {
+		name string
+		// waiters queue up in this order while the only slot is taken
+		waiters []func() context.Context
+		// join raises the priority of these waiters once all of them wait
+		join []int
+		// want is the waiter that gets the slot first
+		want int
+	}{
+		{name: "playback before prefetch", waiters: []func() context.Context{prefetch, prefetch, playback}, want: 2},
+		{name: "joined prefetch before other prefetches", waiters: []func() context.Context{prefetch, joined, prefetch}, join: []int{1}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetMaxProcesses(1)
+			defer SetMaxProcesses(0)
+
+			release, err := acquireProcess(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			acquired := make(chan int, len(tt.waiters))
+			next := make(chan struct{})
+			contexts := make([]context.Context, len(tt.waiters))
+			for i, waiter := range tt.waiters {
+				contexts[i] = waiter()
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					release, err := acquireProcess(contexts[i])
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					acquired <- i
+					<-next
+					release()
+				}()
+				// Let the waiter queue up before the next one
+				time.Sleep(20 * time.Millisecond)
+			}
+			// The remaining waiters run one after the other once checked
+			defer wg.Wait()
+			defer close(next)
+
+			for _, i := range tt.join {
+				priority, _ := contexts[i].Value(priorityKey{}).(*Priority)
+				priority.Join(context.Background())
+			}
+			release()
+
+			select {
+			case got := <-acquired:
+				if got != tt.want {
+					t.Errorf("waiter %d got the slot first, want %d", got, tt.want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("waiter %d never got the slot", tt.want)
+			}
+		})
+	}
+}
+
+func TestAcquireProcessCanceled(t *testing.T) {
+	SetMaxProcesses(1)
+	defer SetMaxProcesses(0)
+
+	release, err := acquireProcess(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	// A playback waiter that gives up must not keep prefetches waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := acquireProcess(ctx); err == nil {
+		t.Fatal("acquired a taken slot")
+	}
+	processMu.Lock()
+	waiting := waitingProcesses
+	processMu.Unlock()
+	if waiting != 0 {
+		t.Errorf("waiting = %d after cancel, want 0", waiting)
+	}
+}
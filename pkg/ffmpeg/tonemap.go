@@ -0,0 +1,89 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"wails-cast/pkg/logger"
+)
+
+// ToneMapOptions converts an HDR source to SDR BT.709
+type ToneMapOptions struct {
+	Transfer  string // source transfer, e.g. "smpte2084"
+	Primaries string // source primaries, e.g. "bt2020"
+}
+
+// Tone mapping filter backends
+const (
+	toneMapperZscale     = "zscale"
+	toneMapperLibplacebo = "libplacebo"
+)
+
+var (
+	toneMapperOnce sync.Once
+	toneMapper     string
+)
+
+// activeToneMapper returns the tone mapping backend compiled into ffmpeg,
+// preferring zscale+tonemap (CPU) over libplacebo (Vulkan), or "" if neither
+// is available
+func activeToneMapper() string {
+	toneMapperOnce.Do(func() {
+		output, err := exec.Command(ffmpegPath, "-hide_banner", "-filters").Output()
+		if err != nil {
+			logger.Logger.Warn("Failed to list ffmpeg filters", "error", err)
+			return
+		}
+
+		// Lines look like " ... zscale            V->V       Apply resizing..."
+		filters := make(map[string]bool)
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 {
+				filters[fields[1]] = true
+			}
+		}
+
+		switch {
+		case filters["zscale"] && filters["tonemap"]:
+			toneMapper = toneMapperZscale
+		case filters["libplacebo"]:
+			toneMapper = toneMapperLibplacebo
+		default:
+			logger.Logger.Warn("No tone mapping filter available, HDR sources will look washed out")
+		}
+	})
+	return toneMapper
+}
+
+// inputArgs returns arguments placed before -i for the tone mapper
+func (opts *ToneMapOptions) inputArgs() []string {
+	if activeToneMapper() == toneMapperLibplacebo {
+		return []string{"-init_hw_device", "vulkan"}
+	}
+	return nil
+}
+
+// filter returns the -vf stage converting HDR frames to 8-bit SDR BT.709
+func (opts *ToneMapOptions) filter() string {
+	switch activeToneMapper() {
+	case toneMapperZscale:
+		primaries := opts.Primaries
+		if primaries == "" {
+			primaries = "bt2020"
+		}
+		// Linearize, map the highlights into SDR range with hable, then
+		// convert to BT.709. The input hints cover files without metadata.
+		return fmt.Sprintf("zscale=tin=%s:pin=%s:min=bt2020nc:t=linear:npl=100,format=gbrpf32le,"+
+			"zscale=p=bt709,tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p",
+			opts.Transfer, primaries)
+	case toneMapperLibplacebo:
+		return "libplacebo=tonemapping=auto:colorspace=bt709:color_primaries=bt709:color_trc=bt709:range=tv:format=yuv420p"
+	}
+	return ""
+}
@@ -2,8 +2,10 @@ package filehelper
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 func WriteFile(path string, data []byte) error {
@@ -42,6 +44,17 @@ func Exists(path string) bool {
 	return err == nil
 }
 
+// UniquePath returns path, or the first free "name (N).ext" next to it when
+// a file already exists there
+func UniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; Exists(path); i++ {
+		path = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	return path
+}
+
 func EnsureDir(path string) error {
 	return os.MkdirAll(path, 0755)
 }
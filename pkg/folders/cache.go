@@ -45,7 +45,7 @@ func GetCacheStats() (*CacheStats, error) {
 		switch {
 		case ext == ".json":
 			stats.MetadataSize += size
-		case ext == ".ts":
+		case isTranscodedSegment(path):
 			// Check if it's a raw segment or transcoded segment
 			if strings.HasSuffix(path, "_raw.ts") {
 				stats.RawSegmentsSize += size
@@ -88,8 +88,8 @@ func DeleteTranscodedCache() error {
 			return nil
 		}
 
-		// Only delete .ts files that have a corresponding .json manifest
-		if strings.HasSuffix(path, ".ts") && !strings.HasSuffix(path, "_raw.ts") {
+		// Only delete .ts/.m4s files that have a corresponding .json manifest
+		if isTranscodedSegment(path) && !strings.HasSuffix(path, "_raw.ts") {
 			jsonManifest := path + ".json"
 			// This is a transcoded segment, delete both the .ts and .json files
 			os.Remove(path)
@@ -115,8 +115,8 @@ func DeleteAllVideoCache() error {
 			return nil
 		}
 
-		// Delete all .ts files and their .json manifests
-		if strings.HasSuffix(path, ".ts") {
+		// Delete all .ts/.m4s files and their .json manifests
+		if isTranscodedSegment(path) {
 			os.Remove(path)
 			// Also remove corresponding .json manifest if it exists
 			jsonManifest := path + ".json"
@@ -126,3 +126,11 @@ func DeleteAllVideoCache() error {
 		return nil
 	})
 }
+
+// isTranscodedSegment matches MPEG-TS and fMP4 segments, including fMP4 init
+// segments
+func isTranscodedSegment(path string) bool {
+	return strings.HasSuffix(path, ".ts") ||
+		strings.HasSuffix(path, ".m4s") ||
+		filepath.Base(path) == "init.mp4"
+}
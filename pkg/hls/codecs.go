@@ -0,0 +1,213 @@
+package hls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CodecInfo is a normalized description of a stream's codecs, using ffprobe
+// codec and profile names so local and remote sources compare the same way
+type CodecInfo struct {
+	Video    string // e.g. "h264", "hevc"
+	Profile  string // e.g. "High", "Main 10"
+	Level    int    // e.g. 41 for level 4.1
+	BitDepth int
+	Audio    string // e.g. "aac", "ac3"
+
+	// Color transfer and primaries, e.g. "smpte2084" and "bt2020"; not
+	// part of CODECS, filled from ffprobe or VIDEO-RANGE
+	Transfer  string
+	Primaries string
+}
+
+// HDR transfer characteristics (ffprobe color_transfer names)
+const (
+	TransferPQ  = "smpte2084"
+	TransferHLG = "arib-std-b67"
+)
+
+// HDR reports whether the video uses an HDR10 (PQ) or HLG transfer
+func (c CodecInfo) HDR() bool {
+	return c.Transfer == TransferPQ || c.Transfer == TransferHLG
+}
+
+// VideoRange returns the HLS VIDEO-RANGE of a transfer: PQ, HLG or SDR
+func VideoRange(transfer string) string {
+	switch transfer {
+	case TransferPQ:
+		return "PQ"
+	case TransferHLG:
+		return "HLG"
+	}
+	return "SDR"
+}
+
+// VideoRangeTransfer returns the transfer signalled by an HLS VIDEO-RANGE,
+// or "" for SDR
+func VideoRangeTransfer(videoRange string) string {
+	switch videoRange {
+	case "PQ":
+		return TransferPQ
+	case "HLG":
+		return TransferHLG
+	}
+	return ""
+}
+
+// avcProfiles maps H.264 profile_idc values to ffprobe profile names
+var avcProfiles = map[int64]string{
+	66:  "Baseline",
+	77:  "Main",
+	88:  "Extended",
+	100: "High",
+	110: "High 10",
+	122: "High 4:2:2",
+	244: "High 4:4:4 Predictive",
+}
+
+// ParseCodecs parses an RFC 6381 CODECS attribute such as
+// "avc1.640028,mp4a.40.2". Unknown entries are ignored.
+func ParseCodecs(codecs string) CodecInfo {
+	var info CodecInfo
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		parts := strings.Split(codec, ".")
+		switch parts[0] {
+		case "avc1", "avc3":
+			info.Video = "h264"
+			info.BitDepth = 8
+			// avc1.PPCCLL: profile_idc, constraint flags, level_idc in hex
+			if len(parts) > 1 && len(parts[1]) == 6 {
+				if profile, err := strconv.ParseInt(parts[1][0:2], 16, 64); err == nil {
+					info.Profile = avcProfiles[profile]
+					// constraint_set1_flag turns Baseline into Constrained Baseline
+					constraints, _ := strconv.ParseInt(parts[1][2:4], 16, 64)
+					if profile == 66 && constraints&0x40 != 0 {
+						info.Profile = "Constrained Baseline"
+					}
+					if profile >= 110 {
+						info.BitDepth = 10
+					}
+				}
+				if level, err := strconv.ParseInt(parts[1][4:6], 16, 64); err == nil {
+					info.Level = int(level)
+				}
+			}
+		case "hvc1", "hev1":
+			info.Video = "hevc"
+			info.BitDepth = 8
+			// hvc1.2.4.L153.B0: general_profile_idc 2 is Main 10
+			if len(parts) > 1 {
+				switch strings.TrimLeft(parts[1], "ABC") {
+				case "1":
+					info.Profile = "Main"
+				case "2":
+					info.Profile = "Main 10"
+					info.BitDepth = 10
+				}
+			}
+			if len(parts) > 3 && len(parts[3]) > 1 {
+				if level, err := strconv.Atoi(parts[3][1:]); err == nil {
+					// HEVC levels are signalled as 30 * level
+					info.Level = level * 10 / 30
+				}
+			}
+		case "vp09":
+			info.Video = "vp9"
+		case "av01":
+			info.Video = "av1"
+		case "mp4a":
+			// mp4a.40.x is AAC; mp4a.40.34 / mp4a.6B are MP3
+			if len(parts) > 2 && parts[1] == "40" && parts[2] == "34" ||
+				len(parts) > 1 && strings.EqualFold(parts[1], "6B") {
+				info.Audio = "mp3"
+			} else {
+				info.Audio = "aac"
+			}
+		case "ac-3":
+			info.Audio = "ac3"
+		case "ec-3":
+			info.Audio = "eac3"
+		case "opus", "Opus":
+			info.Audio = "opus"
+		case "fLaC":
+			info.Audio = "flac"
+		}
+	}
+	return info
+}
+
+// VideoCodecString returns the CODECS entry of an H.264 or HEVC video, or ""
+// when the codec, profile or level is unknown
+func VideoCodecString(info CodecInfo) string {
+	if info.Level <= 0 {
+		return ""
+	}
+	switch info.Video {
+	case "h264":
+		if info.Profile == "Constrained Baseline" {
+			return fmt.Sprintf("avc1.42c0%02x", info.Level)
+		}
+		for profile, name := range avcProfiles {
+			if name == info.Profile {
+				return fmt.Sprintf("avc1.%02x00%02x", profile, info.Level)
+			}
+		}
+	case "hevc":
+		// HEVC levels are signalled as 30 * level
+		switch info.Profile {
+		case "Main":
+			return fmt.Sprintf("hvc1.1.6.L%d.B0", info.Level*30/10)
+		case "Main 10":
+			return fmt.Sprintf("hvc1.2.4.L%d.B0", info.Level*30/10)
+		}
+	}
+	return ""
+}
+
+// ReplaceVideoCodec swaps the video entries of a CODECS attribute for one,
+// keeping the audio entries
+func ReplaceVideoCodec(codecs string, video string) string {
+	entries := []string{video}
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		if codec != "" && ParseCodecs(codec).Audio != "" {
+			entries = append(entries, codec)
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// audioCodecStrings maps ffprobe audio codec names to CODECS entries
+var audioCodecStrings = map[string]string{
+	"aac":  "mp4a.40.2",
+	"mp3":  "mp4a.40.34",
+	"ac3":  "ac-3",
+	"eac3": "ec-3",
+	"opus": "Opus",
+	"flac": "fLaC",
+}
+
+// AudioCodecString returns the CODECS entry for an ffprobe audio codec name,
+// or "" if unknown
+func AudioCodecString(codec string) string {
+	return audioCodecStrings[codec]
+}
+
+// ReplaceAudioCodec swaps the audio entry of a CODECS attribute, appending
+// it when there is none. An empty audio codec removes the audio entry.
+func ReplaceAudioCodec(codecs string, audio string) string {
+	var entries []string
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		if codec == "" || ParseCodecs(codec).Audio != "" {
+			continue
+		}
+		entries = append(entries, codec)
+	}
+	if audio != "" {
+		entries = append(entries, audio)
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,124 @@
+package hls
+
+import "testing"
+
+func TestParseCodecs(t *testing.T) {
+	tests := []struct {
+		codecs string
+		want   CodecInfo
+	}{
+		{codecs: "", want: CodecInfo{}},
+		{codecs: "avc1.640028,mp4a.40.2", want: CodecInfo{Video: "h264", Profile: "High", Level: 40, BitDepth: 8, Audio: "aac"}},
+		{codecs: "avc1.4d401f", want: CodecInfo{Video: "h264", Profile: "Main", Level: 31, BitDepth: 8}},
+		{codecs: "avc3.42c01e", want: CodecInfo{Video: "h264", Profile: "Constrained Baseline", Level: 30, BitDepth: 8}},
+		{codecs: "avc1.42001e", want: CodecInfo{Video: "h264", Profile: "Baseline", Level: 30, BitDepth: 8}},
+		{codecs: "avc1.6e0033", want: CodecInfo{Video: "h264", Profile: "High 10", Level: 51, BitDepth: 10}},
+		{codecs: "avc1", want: CodecInfo{Video: "h264", BitDepth: 8}},
+		{codecs: "hvc1.2.4.L153.B0,ec-3", want: CodecInfo{Video: "hevc", Profile: "Main 10", Level: 51, BitDepth: 10, Audio: "eac3"}},
+		{codecs: "hev1.1.6.L123.90", want: CodecInfo{Video: "hevc", Profile: "Main", Level: 41, BitDepth: 8}},
+		{codecs: "hvc1.A1.6.L93.B0", want: CodecInfo{Video: "hevc", Profile: "Main", Level: 31, BitDepth: 8}},
+		{codecs: "vp09.00.10.08, opus", want: CodecInfo{Video: "vp9", Audio: "opus"}},
+		{codecs: "av01.0.04M.08,fLaC", want: CodecInfo{Video: "av1", Audio: "flac"}},
+		{codecs: "mp4a.40.34", want: CodecInfo{Audio: "mp3"}},
+		{codecs: "mp4a.6B", want: CodecInfo{Audio: "mp3"}},
+		{codecs: "ac-3", want: CodecInfo{Audio: "ac3"}},
+		{codecs: "wvtt,stpp.ttml.im1t", want: CodecInfo{}},
+	}
+	for _, tt := range tests {
+		if got := ParseCodecs(tt.codecs); got != tt.want {
+			t.Errorf("ParseCodecs(%q) = %+v, want %+v", tt.codecs, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceAudioCodec(t *testing.T) {
+	tests := []struct {
+		codecs string
+		audio  string
+		want   string
+	}{
+		{codecs: "avc1.640028,mp4a.40.2", audio: "ac-3", want: "avc1.640028,ac-3"},
+		{codecs: "avc1.640028", audio: "mp4a.40.2", want: "avc1.640028,mp4a.40.2"},
+		{codecs: "avc1.640028, mp4a.40.2", audio: "", want: "avc1.640028"},
+		{codecs: "mp4a.40.2,ec-3", audio: "Opus", want: "Opus"},
+		{codecs: "", audio: "mp4a.40.2", want: "mp4a.40.2"},
+	}
+	for _, tt := range tests {
+		if got := ReplaceAudioCodec(tt.codecs, tt.audio); got != tt.want {
+			t.Errorf("ReplaceAudioCodec(%q, %q) = %q, want %q", tt.codecs, tt.audio, got, tt.want)
+		}
+	}
+}
+
+func TestVideoRange(t *testing.T) {
+	tests := []struct {
+		transfer string
+		want     string
+	}{
+		{transfer: TransferPQ, want: "PQ"},
+		{transfer: TransferHLG, want: "HLG"},
+		{transfer: "bt709", want: "SDR"},
+		{transfer: "", want: "SDR"},
+	}
+	for _, tt := range tests {
+		got := VideoRange(tt.transfer)
+		if got != tt.want {
+			t.Errorf("VideoRange(%q) = %q, want %q", tt.transfer, got, tt.want)
+		}
+		// SDR signals no transfer
+		if back := VideoRangeTransfer(got); got != "SDR" && back != tt.transfer {
+			t.Errorf("VideoRangeTransfer(%q) = %q, want %q", got, back, tt.transfer)
+		}
+	}
+}
+func TestVideoCodecString(t *testing.T) {
+	tests := []struct {
+		name string
+		info CodecInfo
+		want string
+	}{
+		{name: "h264 high", info: CodecInfo{Video: "h264", Profile: "High", Level: 41}, want: "avc1.640029"},
+		{name: "h264 main", info: CodecInfo{Video: "h264", Profile: "Main", Level: 31}, want: "avc1.4d001f"},
+		{name: "h264 constrained baseline", info: CodecInfo{Video: "h264", Profile: "Constrained Baseline", Level: 30}, want: "avc1.42c01e"},
+		{name: "hevc main", info: CodecInfo{Video: "hevc", Profile: "Main", Level: 41}, want: "hvc1.1.6.L123.B0"},
+		{name: "hevc main 10", info: CodecInfo{Video: "hevc", Profile: "Main 10", Level: 51}, want: "hvc1.2.4.L153.B0"},
+		{name: "unknown level", info: CodecInfo{Video: "h264", Profile: "High"}, want: ""},
+		{name: "unknown profile", info: CodecInfo{Video: "h264", Profile: "Foo", Level: 40}, want: ""},
+		{name: "unsupported hevc profile", info: CodecInfo{Video: "hevc", Profile: "Rext", Level: 40}, want: ""},
+		{name: "other codec", info: CodecInfo{Video: "vp9", Profile: "Profile 0", Level: 40}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VideoCodecString(tt.info)
+			if got != tt.want {
+				t.Fatalf("VideoCodecString(%+v) = %q, want %q", tt.info, got, tt.want)
+			}
+			// The string must describe the same video
+			if got == "" {
+				return
+			}
+			parsed := ParseCodecs(got)
+			if parsed.Video != tt.info.Video || parsed.Profile != tt.info.Profile || parsed.Level != tt.info.Level {
+				t.Errorf("ParseCodecs(%q) = %+v, want %+v", got, parsed, tt.info)
+			}
+		})
+	}
+}
+
+func TestReplaceVideoCodec(t *testing.T) {
+	tests := []struct {
+		codecs string
+		video  string
+		want   string
+	}{
+		{codecs: "hvc1.2.4.L153.B0,ec-3", video: "avc1.4d401f", want: "avc1.4d401f,ec-3"},
+		{codecs: "avc1.640028", video: "avc1.4d401f", want: "avc1.4d401f"},
+		{codecs: "mp4a.40.2", video: "avc1.4d401f", want: "avc1.4d401f,mp4a.40.2"},
+		{codecs: "avc1.640028, mp4a.40.2, ac-3", video: "avc1.4d401f", want: "avc1.4d401f,mp4a.40.2,ac-3"},
+	}
+	for _, tt := range tests {
+		if got := ReplaceVideoCodec(tt.codecs, tt.video); got != tt.want {
+			t.Errorf("ReplaceVideoCodec(%q, %q) = %q, want %q", tt.codecs, tt.video, got, tt.want)
+		}
+	}
+}
@@ -113,6 +113,7 @@ func parseManifestPlaylist(lines []string) (*ManifestPlaylist, error) {
 				Resolution: extractAttribute(line, "RESOLUTION"),
 				Audio:      extractAttribute(line, "AUDIO"),
 				Subtitles:  extractAttribute(line, "SUBTITLES"),
+				VideoRange: extractAttribute(line, "VIDEO-RANGE"),
 				Attrs:      parseAttributes(line),
 				Index:      len(manifest.VideoTracks),
 			}
@@ -232,6 +233,9 @@ func (m *ManifestPlaylist) Generate() string {
 		if variant.FrameRate > 0 {
 			attrs = append(attrs, fmt.Sprintf(`FRAME-RATE=%.3f`, variant.FrameRate))
 		}
+		if variant.VideoRange != "" {
+			attrs = append(attrs, fmt.Sprintf(`VIDEO-RANGE=%s`, variant.VideoRange))
+		}
 		if variant.Audio != "" {
 			attrs = append(attrs, fmt.Sprintf(`AUDIO="%s"`, variant.Audio))
 		}
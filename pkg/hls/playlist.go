@@ -40,8 +40,16 @@ type VideoTrack struct {
 	FrameRate  float64
 	Audio      string            // Audio group ID
 	Subtitles  string            // Subtitle group ID
+	VideoRange string            // SDR, PQ or HLG
 	Attrs      map[string]string // Other attributes
 	Index      int               // Index in the manifest playlist
+
+	// Probed details for local files (see ffmpeg.GetMediaTrackInfo)
+	Profile        string
+	Level          int
+	BitDepth       int
+	ColorTransfer  string
+	ColorPrimaries string
 }
 
 // AudioTrack represents an audio track (#EXT-X-MEDIA TYPE=AUDIO)
@@ -55,6 +63,7 @@ type AudioTrack struct {
 	Channels   string
 	Attrs      map[string]string
 	Index      int
+	Codec      string // Probed codec name for local files
 }
 
 // SubtitleTrack represents a subtitle track (#EXT-X-MEDIA TYPE=SUBTITLES)
@@ -68,6 +77,7 @@ type SubtitleTrack struct {
 	Forced     bool
 	Attrs      map[string]string
 	Index      int
+	Codec      string // Probed codec name for local files, e.g. "ass"
 }
 
 // Key represents encryption information (#EXT-X-KEY)
@@ -19,6 +19,12 @@ type TrackPlaylist struct {
 	IndependentSegments bool
 }
 
+// Live reports whether the playlist is still growing: a live or EVENT
+// playlist without #EXT-X-ENDLIST, which must be reloaded
+func (m *TrackPlaylist) Live() bool {
+	return !m.EndList
+}
+
 // Segment represents a media segment
 type Segment struct {
 	Duration        float64
@@ -27,6 +33,7 @@ type Segment struct {
 	ByteRange       *ByteRange
 	Discontinuity   bool
 	Key             *Key // Encryption key (if different from playlist-level)
+	Map             *Map // Init section in effect for this segment
 	ProgramDateTime string
 	Attrs           map[string]string
 }
@@ -38,7 +45,10 @@ func parseTrackPlaylist(lines []string) (*TrackPlaylist, error) {
 	}
 
 	var currentKey *Key
+	var currentMap *Map
 	var nextSegment *Segment
+	// A BYTERANGE without offset continues after the previous segment's range
+	byteRangeContinues := false
 
 	for i := 0; i < len(lines); i++ {
 		line := lines[i]
@@ -60,6 +70,7 @@ func parseTrackPlaylist(lines []string) (*TrackPlaylist, error) {
 			currentKey = &key
 		} else if strings.HasPrefix(line, "#EXT-X-MAP:") {
 			media.Map = parseMap(line)
+			currentMap = media.Map
 		} else if strings.HasPrefix(line, "#EXTINF:") {
 			// Parse duration and title
 			content := strings.TrimPrefix(line, "#EXTINF:")
@@ -75,6 +86,7 @@ func parseTrackPlaylist(lines []string) (*TrackPlaylist, error) {
 				Duration: duration,
 				Title:    title,
 				Key:      currentKey,
+				Map:      currentMap,
 				Attrs:    make(map[string]string),
 			}
 		} else if strings.HasPrefix(line, "#EXT-X-DISCONTINUITY") {
@@ -83,7 +95,9 @@ func parseTrackPlaylist(lines []string) (*TrackPlaylist, error) {
 			}
 		} else if strings.HasPrefix(line, "#EXT-X-BYTERANGE:") {
 			if nextSegment != nil {
-				nextSegment.ByteRange = parseByteRange(strings.TrimPrefix(line, "#EXT-X-BYTERANGE:"))
+				byteRange := strings.TrimPrefix(line, "#EXT-X-BYTERANGE:")
+				nextSegment.ByteRange = parseByteRange(byteRange)
+				byteRangeContinues = !strings.Contains(byteRange, "@")
 			}
 		} else if strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:") {
 			if nextSegment != nil {
@@ -97,6 +111,13 @@ func parseTrackPlaylist(lines []string) (*TrackPlaylist, error) {
 				if err != nil {
 					return nil, fmt.Errorf("failed to parse segment URI: %v", err)
 				}
+				if byteRangeContinues && len(media.Segments) > 0 {
+					previous := media.Segments[len(media.Segments)-1]
+					if previous.ByteRange != nil && previous.URI.String() == line {
+						nextSegment.ByteRange.Offset = previous.ByteRange.Offset + previous.ByteRange.Length
+					}
+				}
+				byteRangeContinues = false
 				media.Segments = append(media.Segments, nextSegment)
 				nextSegment = nil
 			}
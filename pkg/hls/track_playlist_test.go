@@ -0,0 +1,107 @@
+package hls
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTrackPlaylistByteRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist []string
+		want     []ByteRange
+	}{
+		{
+			name: "explicit offsets",
+			playlist: []string{
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:100@0", "media.ts",
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:200@100", "media.ts",
+			},
+			want: []ByteRange{{Length: 100, Offset: 0}, {Length: 200, Offset: 100}},
+		},
+		{
+			name: "range without offset continues the previous one",
+			playlist: []string{
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:100@50", "media.ts",
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:200", "media.ts",
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:300", "media.ts",
+			},
+			want: []ByteRange{{Length: 100, Offset: 50}, {Length: 200, Offset: 150}, {Length: 300, Offset: 350}},
+		},
+		{
+			name: "new URI starts at zero",
+			playlist: []string{
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:100@50", "first.ts",
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:200", "second.ts",
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:300", "second.ts",
+			},
+			want: []ByteRange{{Length: 100, Offset: 50}, {Length: 200, Offset: 0}, {Length: 300, Offset: 200}},
+		},
+		{
+			name: "first range without offset",
+			playlist: []string{
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:100", "media.ts",
+				"#EXTINF:4,", "#EXT-X-BYTERANGE:200", "media.ts",
+			},
+			want: []ByteRange{{Length: 100, Offset: 0}, {Length: 200, Offset: 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n" + strings.Join(tt.playlist, "\n") + "\n#EXT-X-ENDLIST\n"
+			playlist, err := ParseTrackPlaylist(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []ByteRange
+			for _, segment := range playlist.Segments {
+				if segment.ByteRange == nil {
+					t.Fatalf("segment %s has no byte range", segment.URI)
+				}
+				got = append(got, *segment.ByteRange)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("byte ranges = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackPlaylistGenerateMap(t *testing.T) {
+	content := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-VERSION:7",
+		"#EXT-X-TARGETDURATION:4",
+		`#EXT-X-MAP:URI="media.mp4",BYTERANGE="720@0"`,
+		"#EXTINF:4.000000,",
+		"#EXT-X-BYTERANGE:1000@720",
+		"media.mp4",
+		"#EXTINF:4.000000,",
+		"#EXT-X-BYTERANGE:1200@1720",
+		"media.mp4",
+		"#EXT-X-ENDLIST",
+	}, "\n")
+
+	playlist, err := ParseTrackPlaylist(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Map{URI: "media.mp4", ByteRange: &ByteRange{Length: 720, Offset: 0}}
+	if playlist.Map == nil || playlist.Map.URI != want.URI || playlist.Map.ByteRange == nil || *playlist.Map.ByteRange != *want.ByteRange {
+		t.Fatalf("map = %+v, want %+v", playlist.Map, want)
+	}
+
+	generated, err := ParseTrackPlaylist(playlist.Generate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generated.Map == nil || generated.Map.URI != want.URI || generated.Map.ByteRange == nil || *generated.Map.ByteRange != *want.ByteRange {
+		t.Errorf("generated map = %+v, want %+v", generated.Map, want)
+	}
+	for i, segment := range generated.Segments {
+		if *segment.ByteRange != *playlist.Segments[i].ByteRange {
+			t.Errorf("segment %d byte range = %+v, want %+v", i, *segment.ByteRange, *playlist.Segments[i].ByteRange)
+		}
+	}
+}
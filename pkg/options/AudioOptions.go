@@ -0,0 +1,63 @@
+package options
+
+// Audio output modes, chosen per device
+const (
+	// AudioModeStereo downmixes to stereo AAC
+	AudioModeStereo = "stereo"
+	// AudioModeSurroundAAC re-encodes up to 5.1 channels as AAC
+	AudioModeSurroundAAC = "surround-aac"
+	// AudioModeSurroundAC3 re-encodes up to 5.1 channels as AC-3
+	AudioModeSurroundAC3 = "surround-ac3"
+	// AudioModePassthrough stream-copies E-AC-3 and AC-3 for an AV receiver,
+	// re-encoding anything else as 5.1 AC-3
+	AudioModePassthrough = "passthrough"
+)
+
+// AudioOptions configures the audio chain of transcoded segments
+type AudioOptions struct {
+	// Mode is one of the AudioMode constants; empty means stereo
+	Mode string
+	// Loudnorm applies EBU R128 loudness normalization
+	Loudnorm bool
+	// NightMode compresses the dynamic range so loud scenes stay quiet
+	NightMode bool
+	// DialogueBoost raises the center channel when downmixing to stereo
+	DialogueBoost bool
+	// Bitrate is the stereo AAC bitrate, e.g. "128k"; empty uses 96k.
+	// Surround modes use 384k AAC or 448k AC-3.
+	Bitrate string
+}
+
+// Surround reports whether up to 5.1 channels are kept
+func (o AudioOptions) Surround() bool {
+	return o.Mode == AudioModeSurroundAAC || o.Mode == AudioModeSurroundAC3 || o.Mode == AudioModePassthrough
+}
+
+// Codec returns the ffmpeg encoder used when the audio is re-encoded
+func (o AudioOptions) Codec() string {
+	if o.Mode == AudioModeSurroundAC3 || o.Mode == AudioModePassthrough {
+		return "ac3"
+	}
+	return "aac"
+}
+
+// Processed reports whether any filter runs on the audio, which rules out
+// stream-copying it. Dialogue boost only applies to the stereo downmix.
+func (o AudioOptions) Processed() bool {
+	return o.Loudnorm || o.NightMode || o.DialogueBoost && !o.Surround()
+}
+
+// AudioOutput returns the codec (ffprobe name) and channel count of the served
+// audio, given the source's (0 channels when unknown)
+func (o StreamOptions) AudioOutput(sourceCodec string, sourceChannels int) (string, int) {
+	if o.AudioCopied() {
+		return sourceCodec, sourceChannels
+	}
+	if !o.Audio.Surround() {
+		return "aac", 2
+	}
+	if sourceChannels == 0 || sourceChannels > 6 {
+		sourceChannels = 6
+	}
+	return o.Audio.Codec(), sourceChannels
+}
@@ -6,4 +6,8 @@ type CastOptions struct {
 	VideoTrack   int
 	AudioTrack   int
 	Bitrate      string
+	// AspectRatio and Zoom override the picture of this media, see
+	// PictureOptions
+	AspectRatio string
+	Zoom        float64
 }
@@ -0,0 +1,91 @@
+package options
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"wails-cast/pkg/hls"
+)
+
+// DeviceCapabilities describes what a receiver decodes natively
+type DeviceCapabilities struct {
+	VideoCodecs  []string // ffprobe codec names, e.g. "h264"
+	H264Profiles []string // ffprobe profile names, e.g. "High"
+	MaxH264Level int      // e.g. 41 for level 4.1
+	MaxBitDepth  int
+	AudioCodecs  []string
+	// HDR receivers are sent PQ/HLG video as-is instead of tone-mapped
+	HDR bool
+}
+
+// DefaultCapabilities matches a stock Chromecast: 8-bit H.264 up to
+// High@4.1 with AAC or MP3 audio
+var DefaultCapabilities = DeviceCapabilities{
+	VideoCodecs:  []string{"h264"},
+	H264Profiles: []string{"Constrained Baseline", "Baseline", "Main", "High"},
+	MaxH264Level: 41,
+	MaxBitDepth:  8,
+	AudioCodecs:  []string{"aac", "mp3"},
+}
+
+// WithAudioMode returns the capabilities of a receiver using the given audio
+// mode. Passthrough adds the Dolby codecs decoded by the AV receiver.
+func (c DeviceCapabilities) WithAudioMode(mode string) DeviceCapabilities {
+	if mode == AudioModePassthrough {
+		c.AudioCodecs = append(slices.Clone(c.AudioCodecs), "ac3", "eac3")
+	}
+	return c
+}
+
+// WithHDR returns the capabilities of a receiver that keeps HDR, e.g. a
+// Chromecast with Google TV on an HDR display. HDR sources are HEVC Main 10,
+// so that is allowed as well.
+func (c DeviceCapabilities) WithHDR(keep bool) DeviceCapabilities {
+	if keep {
+		c.HDR = true
+		c.VideoCodecs = append(slices.Clone(c.VideoCodecs), "hevc")
+		c.MaxBitDepth = max(c.MaxBitDepth, 10)
+	}
+	return c
+}
+
+// CanPlayVideo reports whether the receiver can decode the video as-is.
+// Unknown details (empty profile, zero level) are treated as unsupported.
+func (c DeviceCapabilities) CanPlayVideo(info hls.CodecInfo) bool {
+	if !slices.Contains(c.VideoCodecs, info.Video) {
+		return false
+	}
+	if info.BitDepth == 0 || info.BitDepth > c.MaxBitDepth {
+		return false
+	}
+	if info.HDR() && !c.HDR {
+		return false
+	}
+	if info.Video == "h264" {
+		return slices.Contains(c.H264Profiles, info.Profile) &&
+			info.Level > 0 && info.Level <= c.MaxH264Level
+	}
+	return true
+}
+
+// CanPlayAudio reports whether the receiver can decode the audio as-is
+func (c DeviceCapabilities) CanPlayAudio(info hls.CodecInfo) bool {
+	return slices.Contains(c.AudioCodecs, info.Audio)
+}
+
+// parseBitrate converts an ffmpeg bitrate such as "5M" or "800k" to bits/s
+func parseBitrate(bitrate string) int {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(bitrate, "M"):
+		multiplier = 1000000
+	case strings.HasSuffix(bitrate, "k"), strings.HasSuffix(bitrate, "K"):
+		multiplier = 1000
+	}
+	value, err := strconv.ParseFloat(strings.TrimRight(bitrate, "MkK"), 64)
+	if err != nil {
+		return 0
+	}
+	return int(value * float64(multiplier))
+}
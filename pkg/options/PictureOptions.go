@@ -0,0 +1,72 @@
+package options
+
+import "fmt"
+
+// Deinterlacing filters
+const (
+	DeinterlaceYadif = "yadif"
+	// DeinterlaceBwdif is slower than yadif but keeps more detail in motion
+	DeinterlaceBwdif = "bwdif"
+)
+
+// PictureOptions configures the picture adjustments of transcoded video
+type PictureOptions struct {
+	// Deinterlace is the filter (yadif or bwdif) applied to sources detected
+	// as interlaced; empty leaves them as is
+	Deinterlace string
+	// AutoCrop removes the black bars detected in the source
+	AutoCrop bool
+	// AspectRatio forces the display aspect ratio, e.g. "4:3", for sources
+	// with a wrong one; empty keeps the source's
+	AspectRatio string
+	// Zoom crops the center of the picture by this factor, e.g. 1.33 to fill
+	// the screen with a windowboxed picture; 0 or 1 disables it
+	Zoom float64
+
+	// Interlaced and Crop ("w:h:x:y") are filled from the source analysis
+	Interlaced bool
+	Crop       string
+}
+
+// Analyzed reports whether the source must be analyzed for interlacing or
+// black bars
+func (o PictureOptions) Analyzed() bool {
+	return o.Deinterlace != "" || o.AutoCrop
+}
+
+// DeinterlaceFilter returns the deinterlacing filter to apply, or ""
+func (o PictureOptions) DeinterlaceFilter() string {
+	if !o.Interlaced {
+		return ""
+	}
+	return o.Deinterlace
+}
+
+// CropArea returns the detected picture area to crop to, or ""
+func (o PictureOptions) CropArea() string {
+	if !o.AutoCrop {
+		return ""
+	}
+	return o.Crop
+}
+
+// Aspect parses AspectRatio, reporting false when it is empty or invalid
+func (o PictureOptions) Aspect() (int, int, bool) {
+	var width, height int
+	if _, err := fmt.Sscanf(o.AspectRatio, "%d:%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// Zoomed reports whether the picture is zoomed in
+func (o PictureOptions) Zoomed() bool {
+	return o.Zoom > 1
+}
+
+// Filtered reports whether any filter runs on the picture, which rules out
+// stream-copying the video
+func (o PictureOptions) Filtered() bool {
+	_, _, aspect := o.Aspect()
+	return o.DeinterlaceFilter() != "" || o.CropArea() != "" || aspect || o.Zoomed()
+}
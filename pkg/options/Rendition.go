@@ -0,0 +1,55 @@
+package options
+
+// Rendition is one lower-bitrate step of an adaptive bitrate ladder
+type Rendition struct {
+	Bitrate  string
+	MaxWidth int
+}
+
+// DefaultLadder is offered below the original stream in ABR mode
+var DefaultLadder = []Rendition{
+	{Bitrate: "5M", MaxWidth: 1920},
+	{Bitrate: "3M", MaxWidth: 1280},
+	{Bitrate: "1.5M", MaxWidth: 854},
+}
+
+// Bandwidth returns the rendition's video bitrate in bits/s
+func (r Rendition) Bandwidth() int {
+	return parseBitrate(r.Bitrate)
+}
+
+// LadderBelow returns the renditions of the ladder with a lower bitrate than
+// the original stream. A bandwidth of 0 (unknown) keeps the whole ladder.
+func (o StreamOptions) LadderBelow(bandwidth int) []Rendition {
+	if o.Bitrate != "" {
+		bandwidth = parseBitrate(o.Bitrate)
+	}
+	var ladder []Rendition
+	for _, rendition := range o.Renditions {
+		if bandwidth == 0 || rendition.Bandwidth() < bandwidth {
+			ladder = append(ladder, rendition)
+		}
+	}
+	return ladder
+}
+
+// ForRendition returns the options used to transcode a rendition. Renditions
+// are always re-encoded and never have renditions of their own.
+func (o StreamOptions) ForRendition(rendition Rendition) StreamOptions {
+	o.Bitrate = rendition.Bitrate
+	if rendition.MaxWidth > 0 && (o.MaxOutputWidth == 0 || rendition.MaxWidth < o.MaxOutputWidth) {
+		o.MaxOutputWidth = rendition.MaxWidth
+	}
+	o.CopyVideo = false
+	o.Renditions = nil
+	return o
+}
+
+// VideoBandwidth returns the bitrate of the served video, given the source's
+// bandwidth (0 when unknown)
+func (o StreamOptions) VideoBandwidth(source int) int {
+	if !o.VideoCopied() && o.Bitrate != "" {
+		return parseBitrate(o.Bitrate)
+	}
+	return source
+}
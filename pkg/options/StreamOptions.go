@@ -1,5 +1,15 @@
 package options
 
+import "wails-cast/pkg/hls"
+
+// Segment containers served to the receiver
+const (
+	ContainerMPEGTS = "mpegts"
+	// ContainerFMP4 serves fragmented MP4 (CMAF) segments with an init
+	// segment, required by some receivers for HEVC and HDR
+	ContainerFMP4 = "fmp4"
+)
+
 // StreamOptions holds options for streaming
 type StreamOptions struct {
 	Subtitle         SubtitleCastOptions
@@ -8,4 +18,77 @@ type StreamOptions struct {
 	Bitrate          string
 	MaxOutputWidth   int
 	NoTranscodeCache bool
+	Container        string
+	Audio            AudioOptions
+	Picture          PictureOptions
+
+	// Renditions are published as extra ABR variants below the original
+	// stream; empty serves a single variant
+	Renditions []Rendition
+
+	// DirectPlay allows stream-copying tracks the receiver decodes natively
+	DirectPlay   bool
+	Capabilities DeviceCapabilities
+	// CopyVideo / CopyAudio are set by ResolveCopyMode for the source being
+	// streamed; use VideoCopied / AudioCopied to account for burn-in.
+	CopyVideo bool
+	CopyAudio bool
+	// Source is the codec description passed to ResolveCopyMode
+	Source hls.CodecInfo
+}
+
+// ResolveCopyMode decides per track whether the source can be stream-copied.
+// Video is only copied when it does not need downscaling or a lower bitrate.
+// width and bandwidth may be 0 when unknown.
+func (o *StreamOptions) ResolveCopyMode(source hls.CodecInfo, width int, bandwidth int) {
+	o.Source = source
+	o.CopyVideo = false
+	o.CopyAudio = false
+	if !o.DirectPlay {
+		return
+	}
+
+	o.CopyAudio = o.Capabilities.CanPlayAudio(source)
+
+	if !o.Capabilities.CanPlayVideo(source) {
+		return
+	}
+	if o.MaxOutputWidth > 0 && (width == 0 || width > o.MaxOutputWidth) {
+		return
+	}
+	if o.Bitrate != "" && (bandwidth == 0 || bandwidth > parseBitrate(o.Bitrate)) {
+		return
+	}
+	o.CopyVideo = true
+}
+
+// VideoCopied reports whether video segments are stream-copied. Burning in
+// subtitles or filtering the picture always needs a re-encode.
+func (o StreamOptions) VideoCopied() bool {
+	return o.CopyVideo && !o.Subtitle.BurnsIn() && !o.Picture.Filtered()
+}
+
+// FragmentedMP4 reports whether segments are served as fMP4 with an init segment
+func (o StreamOptions) FragmentedMP4() bool {
+	return o.Container == ContainerFMP4
+}
+
+// SegmentExtension returns the file extension of served segments
+func (o StreamOptions) SegmentExtension() string {
+	if o.FragmentedMP4() {
+		return "m4s"
+	}
+	return "ts"
+}
+
+// ToneMapped reports whether HDR video is converted to SDR. Re-encoded HDR
+// sources are always tone-mapped since the output is 8-bit H.264.
+func (o StreamOptions) ToneMapped() bool {
+	return o.Source.HDR() && !o.VideoCopied()
+}
+
+// AudioCopied reports whether audio segments are stream-copied. Audio
+// processing always needs a re-encode.
+func (o StreamOptions) AudioCopied() bool {
+	return o.CopyAudio && !o.Audio.Processed()
 }
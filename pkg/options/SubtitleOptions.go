@@ -14,4 +14,15 @@ type SubtitleCastOptions struct {
 	// the ffmpeg subtitles force_style directive.
 	Bold   bool
 	Italic bool
+	// ForceStyle applies FontSize, Bold and Italic over the styles of
+	// burned-in ASS/SSA subtitles, which otherwise render as authored.
+	ForceStyle bool
+	// Bitmap is set by the stream handler when Path is an image-based track
+	// (PGS, VobSub, DVB), which can only be burned in
+	Bitmap bool `json:"-"`
+}
+
+// BurnsIn reports whether a subtitle track is actually rendered into the video
+func (o SubtitleCastOptions) BurnsIn() bool {
+	return (o.BurnIn || o.Bitmap) && o.Path != "" && o.Path != "none"
 }
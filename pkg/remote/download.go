@@ -0,0 +1,265 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+	"wails-cast/pkg/filehelper"
+	"wails-cast/pkg/logger"
+)
+
+var (
+	parallelismMu sync.Mutex
+	// parallelism is the number of segments a track downloads at once
+	parallelism = 4
+)
+
+// SetDownloadParallelism sets how many segments of a track are downloaded at
+// once (at least 1). Running downloads keep their workers.
+func SetDownloadParallelism(n int) {
+	parallelismMu.Lock()
+	defer parallelismMu.Unlock()
+	parallelism = max(n, 1)
+}
+
+func downloadParallelism() int {
+	parallelismMu.Lock()
+	defer parallelismMu.Unlock()
+	return parallelism
+}
+
+// failedSegmentsFile keeps the failure list of a track across restarts
+const failedSegmentsFile = "failed_segments.json"
+
+func (this *TrackManager) StartDownload() error {
+	return this.startDownload(nil)
+}
+
+// RetryFailed downloads again only the segments that failed, without
+// restarting the whole track. Without recorded failures it resumes the
+// track.
+func (this *TrackManager) RetryFailed() error {
+	failed := this.FailedSegments()
+	if len(failed) == 0 {
+		return this.startDownload(nil)
+	}
+	return this.startDownload(failed)
+}
+
+// startDownload downloads the given segments, or all segments when only is
+// nil, with downloadParallelism workers. A failing segment is recorded and
+// the download goes on with the others. Segments are only queued inside the
+// download window.
+func (this *TrackManager) startDownload(only []int) error {
+	this.statusMu.Lock()
+	if this.downloadStatus == "INPROGRESS" || this.downloadStatus == "SCHEDULED" {
+		this.statusMu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(withBackground(context.Background()))
+	this.cancelDownload = cancel
+	this.downloadStatus = "INPROGRESS"
+	this.statusMu.Unlock()
+	this.statusUpdate()
+
+	go func() {
+		defer cancel()
+
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		for range downloadParallelism() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					this.fetchSegment(ctx, i)
+				}
+			}()
+		}
+
+		if only != nil {
+			this.queueSegments(ctx, indexes, only)
+		} else {
+			this.queueAllSegments(ctx, indexes)
+		}
+		close(indexes)
+		wg.Wait()
+
+		status := "COMPLETED"
+		switch {
+		case len(this.FailedSegments()) > 0:
+			status = "ERROR"
+		case only != nil && !this.allDownloaded():
+			status = "STOPPED"
+		}
+		this.finishDownload(ctx, status)
+	}()
+
+	return nil
+}
+
+// finishDownload reports the final status of a download. It is checked under
+// the lock StopDownload cancels with, so a stopped or restarted download
+// keeps the status StopDownload reported.
+func (this *TrackManager) finishDownload(ctx context.Context, status string) {
+	this.statusMu.Lock()
+	if ctx.Err() != nil {
+		this.statusMu.Unlock()
+		return
+	}
+	this.downloadStatus = status
+	this.cancelDownload = nil
+	this.statusMu.Unlock()
+	this.statusUpdate()
+}
+
+// queueSegments hands the given segments to the workers
+func (this *TrackManager) queueSegments(ctx context.Context, indexes chan<- int, segments []int) {
+	for _, i := range segments {
+		if this.waitDownloadWindow(ctx) != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case indexes <- i:
+		}
+	}
+}
+
+// queueAllSegments hands every segment to the workers, following a live
+// playlist until the stream ends
+func (this *TrackManager) queueAllSegments(ctx context.Context, indexes chan<- int) {
+	// Segments before the live window have expired at the source
+	this.mu.RLock()
+	i := this.windowStart
+	this.mu.RUnlock()
+	for {
+		playlist := this.Playlist()
+		if i >= len(playlist.Segments) {
+			if !playlist.Live() {
+				return
+			}
+			// Record a live stream until it ends
+			this.keepRefreshing()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(targetDuration(playlist)):
+			}
+			continue
+		}
+
+		if this.waitDownloadWindow(ctx) != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case indexes <- i:
+		}
+		i++
+	}
+}
+
+// fetchSegment downloads one segment and updates the failure list
+func (this *TrackManager) fetchSegment(ctx context.Context, segmentIndex int) {
+	// Playback may have fetched a segment since it failed
+	if this.isDownloaded(segmentIndex) {
+		this.setFailed(segmentIndex, "")
+		return
+	}
+	_, err := this.GetSegment(ctx, segmentIndex)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		logger.Logger.Warn("Failed to download segment", "type", this.TrackType, "track", this.TrackIndex, "segment", segmentIndex, "error", err)
+		this.setFailed(segmentIndex, err.Error())
+		return
+	}
+	this.setFailed(segmentIndex, "")
+}
+
+// FailedSegments returns the sorted indexes of the segments whose last
+// download failed
+func (this *TrackManager) FailedSegments() []int {
+	this.failedMu.Lock()
+	defer this.failedMu.Unlock()
+	return slices.Sorted(maps.Keys(this.failedSegments))
+}
+
+// setFailed records the error of a segment, or clears it when message is
+// empty, and persists the list
+func (this *TrackManager) setFailed(segmentIndex int, message string) {
+	this.failedMu.Lock()
+	defer this.failedMu.Unlock()
+
+	_, found := this.failedSegments[segmentIndex]
+	if message == "" && !found {
+		return
+	}
+	if this.failedSegments == nil {
+		this.failedSegments = make(map[int]string)
+	}
+	if message == "" {
+		delete(this.failedSegments, segmentIndex)
+	} else {
+		this.failedSegments[segmentIndex] = message
+	}
+
+	if err := filehelper.WriteJson(filepath.Join(this.Folder, failedSegmentsFile), &this.failedSegments); err != nil {
+		logger.Logger.Warn("Failed to save failed segments", "folder", this.Folder, "error", err)
+	}
+	this.statusUpdate()
+}
+
+// clearFailed forgets all failures, after the segments were removed
+func (this *TrackManager) clearFailed() {
+	this.failedMu.Lock()
+	defer this.failedMu.Unlock()
+	this.failedSegments = nil
+}
+
+// loadFailedSegments reads the failure list saved by a previous session
+func loadFailedSegments(folder string) map[int]string {
+	failed, err := filehelper.ReadJson[map[int]string](filepath.Join(folder, failedSegmentsFile))
+	if err != nil || failed == nil {
+		return nil
+	}
+	return *failed
+}
+
+func (this *TrackManager) allDownloaded() bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return !slices.Contains(this.downloadedSegments, false)
+}
+
+// SegmentFiles returns the downloaded segment files of the track in
+// playback order. It fails unless every segment is downloaded.
+func (this *TrackManager) SegmentFiles() ([]string, error) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	if this.Manifest.Live() {
+		return nil, fmt.Errorf("live stream has not ended")
+	}
+
+	var files []string
+	missing := 0
+	// Segments before the live window expired before they were seen
+	for i := this.windowStart; i < len(this.Manifest.Segments); i++ {
+		if !this.downloadedSegments[i] {
+			missing++
+		}
+		files = append(files, getSegmentPath(this.Folder, i))
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("%d of %d segments are not downloaded", missing, len(files))
+	}
+	return files, nil
+}
@@ -3,7 +3,10 @@ package remote
 type DownloadStatus struct {
 	Status    string
 	Segments  []bool
+	Failed    []int
 	URL       string
 	MediaType string
 	Track     int
+	// RateLimit is the per media limit in kilobytes per second
+	RateLimit int
 }
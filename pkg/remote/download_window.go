@@ -0,0 +1,112 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// windowRecheck is how often a paused download re-reads the window, so
+// changed settings apply without restarting it
+const windowRecheck = time.Minute
+
+// DownloadWindow is a daily time range in which background downloads run.
+// A range ending before it starts wraps around midnight.
+type DownloadWindow struct {
+	Start time.Duration // Since midnight
+	End   time.Duration
+}
+
+var (
+	windowMu sync.Mutex
+	// downloadWindow is nil when downloads may run at any time
+	downloadWindow *DownloadWindow
+)
+
+// SetDownloadWindow restricts background downloads to a daily range such as
+// "01:00" to "07:00". Empty or equal times remove the restriction.
+func SetDownloadWindow(start string, end string) error {
+	window, err := ParseDownloadWindow(start, end)
+	if err != nil {
+		return err
+	}
+	windowMu.Lock()
+	defer windowMu.Unlock()
+	downloadWindow = window
+	return nil
+}
+
+// ParseDownloadWindow parses a range of "HH:MM" times, returning nil for an
+// unrestricted range
+func ParseDownloadWindow(start string, end string) (*DownloadWindow, error) {
+	if start == "" || end == "" || start == end {
+		return nil, nil
+	}
+	startTime, err := time.Parse("15:04", start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download window start %q", start)
+	}
+	endTime, err := time.Parse("15:04", end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download window end %q", end)
+	}
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &DownloadWindow{
+		Start: startTime.Sub(midnight),
+		End:   endTime.Sub(midnight),
+	}, nil
+}
+
+// Wait returns how long to wait from now until the window opens, 0 when it
+// is open
+func (this *DownloadWindow) Wait(now time.Time) time.Duration {
+	if this == nil {
+		return 0
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+
+	open := offset >= this.Start && offset < this.End
+	if this.End < this.Start {
+		open = offset >= this.Start || offset < this.End
+	}
+	if open {
+		return 0
+	}
+	wait := this.Start - offset
+	if wait < 0 {
+		wait += 24 * time.Hour
+	}
+	return wait
+}
+
+func currentDownloadWindow() *DownloadWindow {
+	windowMu.Lock()
+	defer windowMu.Unlock()
+	return downloadWindow
+}
+
+// waitDownloadWindow pauses a download while it is outside the download
+// window, reporting it as SCHEDULED, and resumes it when the window opens
+func (this *TrackManager) waitDownloadWindow(ctx context.Context) error {
+	for {
+		wait := currentDownloadWindow().Wait(time.Now())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if wait == 0 {
+			this.switchStatus("SCHEDULED", "INPROGRESS")
+			return nil
+		}
+		this.switchStatus("INPROGRESS", "SCHEDULED")
+
+		timer := time.NewTimer(min(wait, windowRecheck))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
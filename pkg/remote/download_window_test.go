@@ -0,0 +1,74 @@
+package remote
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDownloadWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		want    *DownloadWindow
+		wantErr bool
+	}{
+		{name: "unrestricted", start: "", end: ""},
+		{name: "missing end", start: "01:00", end: ""},
+		{name: "equal times", start: "03:00", end: "03:00"},
+		{name: "night", start: "01:00", end: "07:30", want: &DownloadWindow{Start: time.Hour, End: 7*time.Hour + 30*time.Minute}},
+		{name: "wraps midnight", start: "23:00", end: "06:00", want: &DownloadWindow{Start: 23 * time.Hour, End: 6 * time.Hour}},
+		{name: "invalid start", start: "25:00", end: "06:00", wantErr: true},
+		{name: "invalid end", start: "01:00", end: "7h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDownloadWindow(tt.start, tt.end)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("window = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Errorf("window = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadWindowWait(t *testing.T) {
+	night := &DownloadWindow{Start: time.Hour, End: 7 * time.Hour}
+	overnight := &DownloadWindow{Start: 23 * time.Hour, End: 6 * time.Hour}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 10, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		window *DownloadWindow
+		now    time.Time
+		want   time.Duration
+	}{
+		{name: "unrestricted", window: nil, now: at(12, 0), want: 0},
+		{name: "at the start", window: night, now: at(1, 0), want: 0},
+		{name: "inside", window: night, now: at(4, 30), want: 0},
+		{name: "at the end", window: night, now: at(7, 0), want: 18 * time.Hour},
+		{name: "before", window: night, now: at(0, 15), want: 45 * time.Minute},
+		{name: "after", window: night, now: at(22, 0), want: 3 * time.Hour},
+		{name: "overnight before midnight", window: overnight, now: at(23, 30), want: 0},
+		{name: "overnight after midnight", window: overnight, now: at(2, 0), want: 0},
+		{name: "overnight closed", window: overnight, now: at(12, 0), want: 11 * time.Hour},
+		{name: "overnight at the end", window: overnight, now: at(6, 0), want: 17 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Wait(tt.now); got != tt.want {
+				t.Errorf("Wait(%s) = %s, want %s", tt.now.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
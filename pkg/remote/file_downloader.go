@@ -2,54 +2,213 @@ package remote
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+	"wails-cast/pkg/logger"
 )
 
+// downloadAttempts is how often a request is tried before failing
+const downloadAttempts = 4
+
+// Retries wait a random time up to a delay doubling from retryBaseDelay to
+// retryMaxDelay
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+)
+
+// httpClient is shared by all downloads so connections to a host are pooled
+var httpClient = &http.Client{
+	Transport: newTransport(),
+	Timeout:   30 * time.Second,
+}
+
+// throttledClient shares the pool of httpClient but has no overall timeout,
+// as rate limited bodies may take longer to read
+var throttledClient = &http.Client{
+	Transport: httpClient.Transport,
+}
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Parallel segment downloads all go to the same CDN host
+	transport.MaxIdleConnsPerHost = 16
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return transport
+}
+
+// StatusError is returned when a server answers with an unexpected status
+type StatusError struct {
+	StatusCode int
+	Range      string
+}
+
+func (e *StatusError) Error() string {
+	if e.Range != "" {
+		return fmt.Sprintf("status code %d for range %s", e.StatusCode, e.Range)
+	}
+	return fmt.Sprintf("status code %d", e.StatusCode)
+}
+
 type FileDownloader struct {
 	Cookies map[string]string
 	Headers map[string]string
+	// Limiter throttles the background downloads of one media, on top of
+	// the global limit. Nil means no per media limit.
+	Limiter *RateLimiter
+	// Reauthorize extracts the media again when the source refuses the
+	// captured cookies, headers or signed URLs, see isAuthFailure
+	Reauthorize func(ctx context.Context) error
+
+	// mu guards Cookies and Headers, replaced by SetCredentials
+	mu sync.RWMutex
+}
+
+// SetCredentials replaces the cookies and headers sent with every request
+func (p *FileDownloader) SetCredentials(cookies map[string]string, headers map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Cookies = cookies
+	p.Headers = headers
 }
 
 // DownloadFile downloads a file with cookies and headers
 func (p *FileDownloader) DownloadFile(ctx context.Context, url *url.URL) ([]byte, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	req, err := http.NewRequestWithContext(ctx, "GET", url.String(), nil)
+	return p.download(ctx, url, "")
+}
+
+// DownloadRange downloads length bytes of a file starting at offset, as
+// addressed by #EXT-X-BYTERANGE
+func (p *FileDownloader) DownloadRange(ctx context.Context, url *url.URL, offset int64, length int64) ([]byte, error) {
+	data, err := p.download(ctx, url, fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
 	if err != nil {
 		return nil, err
 	}
-
-	for key, value := range p.Headers {
-		req.Header.Set(key, value)
+	if int64(len(data)) != length {
+		return nil, fmt.Errorf("range %d@%d returned %d bytes", length, offset, len(data))
 	}
+	return data, nil
+}
 
-	if len(p.Cookies) > 0 {
-		var cookieParts []string
-		for key, value := range p.Cookies {
-			cookieParts = append(cookieParts, fmt.Sprintf("%s=%s", key, value))
+// download fetches a file, retrying network errors, timeouts, rate limits and
+// server errors with exponential backoff
+func (p *FileDownloader) download(ctx context.Context, url *url.URL, byteRange string) ([]byte, error) {
+	var err error
+	for attempt := range downloadAttempts {
+		if attempt > 0 {
+			if waitErr := waitRetry(ctx, attempt); waitErr != nil {
+				return nil, waitErr
+			}
 		}
-		req.Header.Set("Cookie", strings.Join(cookieParts, "; "))
+		var data []byte
+		data, err = p.fetch(ctx, url, byteRange)
+		if err == nil || !retryable(ctx, err) {
+			return data, err
+		}
+		logger.Logger.Debug("Download failed, retrying", "url", url.String(), "attempt", attempt+1, "error", err)
 	}
+	return nil, err
+}
 
-	resp, err := client.Do(req)
+func (p *FileDownloader) fetch(ctx context.Context, url *url.URL, byteRange string) ([]byte, error) {
+	client := httpClient
+	throttled := isBackground(ctx)
+	if throttled {
+		client = throttledClient
+	}
+	resp, err := p.do(ctx, client, "GET", url, byteRange)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	// Servers ignoring the Range header answer 200 with the whole file
+	if byteRange != "" && resp.StatusCode != http.StatusPartialContent {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Range: byteRange}
+	}
+	if byteRange == "" && resp.StatusCode != 200 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if throttled {
+		limiters := []*RateLimiter{globalLimiter}
+		if p.Limiter != nil {
+			limiters = append(limiters, p.Limiter)
+		}
+		body = &rateLimitedReader{ctx: ctx, reader: resp.Body, limiters: limiters}
+	}
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return nil, err
 	}
 	return data, nil
 }
+
+// retryable reports whether a failed request may succeed when repeated
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusRequestTimeout,
+			statusErr.StatusCode == http.StatusTooManyRequests,
+			statusErr.StatusCode >= 500:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// waitRetry sleeps before a retry, with full jitter so parallel downloads do
+// not hit the server again at the same time
+func waitRetry(ctx context.Context, attempt int) error {
+	delay := min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+	timer := time.NewTimer(rand.N(delay) + 1)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do sends a request with the captured cookies and headers; byteRange is a
+// Range header value or empty
+func (p *FileDownloader) do(ctx context.Context, client *http.Client, method string, url *url.URL, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+
+	p.mu.RLock()
+	for key, value := range p.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if len(p.Cookies) > 0 {
+		var cookieParts []string
+		for key, value := range p.Cookies {
+			cookieParts = append(cookieParts, fmt.Sprintf("%s=%s", key, value))
+		}
+		req.Header.Set("Cookie", strings.Join(cookieParts, "; "))
+	}
+	p.mu.RUnlock()
+
+	return client.Do(req)
+}
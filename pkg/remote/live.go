@@ -0,0 +1,129 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	"wails-cast/pkg/hls"
+	"wails-cast/pkg/logger"
+)
+
+// liveIdleTargets is how many target durations a live playlist keeps being
+// refreshed after its window was last polled
+const liveIdleTargets = 10
+
+// Playlist returns the current track playlist. Live playlists are replaced
+// on every refresh, so the snapshot stays consistent for the caller.
+func (this *TrackManager) Playlist() *hls.TrackPlaylist {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.Manifest
+}
+
+// Live reports whether the source playlist is still growing
+func (this *TrackManager) Live() bool {
+	return this.Playlist().Live()
+}
+
+// Window returns the playlist and the index of its first segment still
+// listed by the source. Polling the window keeps a live track refreshed.
+func (this *TrackManager) Window() (*hls.TrackPlaylist, int) {
+	this.keepRefreshing()
+
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.Manifest, this.windowStart
+}
+
+// keepRefreshing starts the refresh loop of a live track if it is not running
+func (this *TrackManager) keepRefreshing() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.lastPoll = time.Now()
+	if this.refreshing || !this.Manifest.Live() || this.Resolver == nil {
+		return
+	}
+	this.refreshing = true
+	go this.refreshLoop()
+}
+
+// refreshLoop reloads the playlist every target duration until the stream
+// ends or nobody polls it anymore
+func (this *TrackManager) refreshLoop() {
+	for {
+		this.mu.Lock()
+		interval := targetDuration(this.Manifest)
+		if !this.Manifest.Live() || time.Since(this.lastPoll) > liveIdleTargets*interval {
+			this.refreshing = false
+			this.mu.Unlock()
+			return
+		}
+		this.mu.Unlock()
+
+		time.Sleep(interval)
+		if err := this.Refresh(context.Background()); err != nil {
+			logger.Logger.Warn("Failed to refresh live playlist", "type", this.TrackType, "track", this.TrackIndex, "error", err)
+		}
+	}
+}
+
+// Refresh reloads a live playlist and appends its new segments. Segments
+// keep the index they got when first seen, so the served playlist can slide
+// over them without renumbering.
+func (this *TrackManager) Refresh(ctx context.Context) error {
+	latest, _, err := this.Resolver.RefreshPlaylist(ctx)
+	if err != nil {
+		return err
+	}
+	this.merge(latest)
+	this.statusUpdate()
+	return nil
+}
+
+// merge appends the segments of a reloaded playlist that follow the last
+// known media sequence number
+func (this *TrackManager) merge(latest *hls.TrackPlaylist) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	merged := *latest
+	merged.MediaSequence = this.Manifest.MediaSequence
+	merged.Segments = slices.Clip(this.Manifest.Segments)
+
+	skip := this.nextSequence - latest.MediaSequence
+	gap := skip < 0
+	if gap {
+		logger.Logger.Warn("Live playlist moved past unseen segments", "type", this.TrackType, "track", this.TrackIndex, "missed", -skip)
+		skip = 0
+	}
+
+	for i := skip; i < len(latest.Segments); i++ {
+		segment := latest.Segments[i]
+		sequence := latest.MediaSequence + i
+		// After a gap the index no longer follows the sequence number the
+		// segment is decrypted with, so the IV is made explicit
+		if sequence != merged.MediaSequence+len(merged.Segments) {
+			copy := *segment
+			copy.Discontinuity = copy.Discontinuity || gap && i == skip
+			if copy.Key != nil && copy.Key.IV == "" {
+				key := *copy.Key
+				key.IV = fmt.Sprintf("0x%032X", sequence)
+				copy.Key = &key
+			}
+			segment = &copy
+		}
+		merged.Segments = append(merged.Segments, segment)
+	}
+
+	this.nextSequence = max(this.nextSequence, latest.MediaSequence+len(latest.Segments))
+	this.windowStart = max(this.windowStart, len(merged.Segments)-len(latest.Segments))
+	this.downloadedSegments = append(this.downloadedSegments, make([]bool, len(merged.Segments)-len(this.downloadedSegments))...)
+	this.Manifest = &merged
+}
+
+// targetDuration returns the reload interval of a playlist
+func targetDuration(playlist *hls.TrackPlaylist) time.Duration {
+	return time.Duration(max(playlist.TargetDuration, 1)) * time.Second
+}
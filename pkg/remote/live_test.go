@@ -0,0 +1,119 @@
+package remote
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"testing"
+	"wails-cast/pkg/hls"
+)
+
+// livePlaylist returns a live playlist of count AES-128 segments named after
+// their media sequence number, starting at first
+func livePlaylist(first int, count int) *hls.TrackPlaylist {
+	key := &hls.Key{Method: keyMethodAES128, URI: "key.bin"}
+	playlist := &hls.TrackPlaylist{TargetDuration: 4, MediaSequence: first}
+	for sequence := first; sequence < first+count; sequence++ {
+		playlist.Segments = append(playlist.Segments, &hls.Segment{
+			Duration: 4,
+			URI:      &url.URL{Path: fmt.Sprintf("seg%d.ts", sequence)},
+			Key:      key,
+		})
+	}
+	return playlist
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		latest *hls.TrackPlaylist
+		// want lists the merged segments, windowStart the first one still
+		// listed by the source
+		want        []string
+		windowStart int
+		// discontinuities and ivs are the segments marked after a gap, and
+		// the explicit IVs they got
+		discontinuities []int
+		ivs             map[int]string
+	}{
+		{
+			name:        "nothing new",
+			latest:      livePlaylist(10, 3),
+			want:        []string{"seg10.ts", "seg11.ts", "seg12.ts"},
+			windowStart: 0,
+		},
+		{
+			name:        "overlap",
+			latest:      livePlaylist(11, 4),
+			want:        []string{"seg10.ts", "seg11.ts", "seg12.ts", "seg13.ts", "seg14.ts"},
+			windowStart: 1,
+		},
+		{
+			name:            "gap",
+			latest:          livePlaylist(14, 2),
+			want:            []string{"seg10.ts", "seg11.ts", "seg12.ts", "seg14.ts", "seg15.ts"},
+			windowStart:     3,
+			discontinuities: []int{3},
+			ivs:             map[int]string{3: "0x0000000000000000000000000000000E", 4: "0x0000000000000000000000000000000F"},
+		},
+		{
+			name:            "window past all cached segments",
+			latest:          livePlaylist(40, 4),
+			want:            []string{"seg10.ts", "seg11.ts", "seg12.ts", "seg40.ts", "seg41.ts", "seg42.ts", "seg43.ts"},
+			windowStart:     3,
+			discontinuities: []int{3},
+			ivs: map[int]string{
+				3: "0x00000000000000000000000000000028",
+				4: "0x00000000000000000000000000000029",
+				5: "0x0000000000000000000000000000002A",
+				6: "0x0000000000000000000000000000002B",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached := livePlaylist(10, 3)
+			manager := &TrackManager{
+				Manifest:           cached,
+				nextSequence:       13,
+				downloadedSegments: make([]bool, len(cached.Segments)),
+			}
+
+			manager.merge(tt.latest)
+
+			playlist, windowStart := manager.Manifest, manager.windowStart
+			var uris []string
+			var discontinuities []int
+			for i, segment := range playlist.Segments {
+				uris = append(uris, segment.URI.String())
+				if segment.Discontinuity {
+					discontinuities = append(discontinuities, i)
+				}
+				if segment.Key.IV != tt.ivs[i] {
+					t.Errorf("segment %d IV = %q, want %q", i, segment.Key.IV, tt.ivs[i])
+				}
+			}
+			if !slices.Equal(uris, tt.want) {
+				t.Errorf("segments = %v, want %v", uris, tt.want)
+			}
+			if !slices.Equal(discontinuities, tt.discontinuities) {
+				t.Errorf("discontinuities = %v, want %v", discontinuities, tt.discontinuities)
+			}
+			if windowStart != tt.windowStart {
+				t.Errorf("windowStart = %d, want %d", windowStart, tt.windowStart)
+			}
+			if playlist.MediaSequence != 10 {
+				t.Errorf("media sequence = %d, want 10", playlist.MediaSequence)
+			}
+			if len(manager.downloadedSegments) != len(tt.want) {
+				t.Errorf("%d download flags, want %d", len(manager.downloadedSegments), len(tt.want))
+			}
+			// Segments already cached are kept as they were
+			for i := range cached.Segments {
+				if playlist.Segments[i] != cached.Segments[i] {
+					t.Errorf("cached segment %d was replaced", i)
+				}
+			}
+		})
+	}
+}
@@ -3,8 +3,14 @@ package remote
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net/url"
+	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+	"wails-cast/pkg/dash"
 	"wails-cast/pkg/events"
 	"wails-cast/pkg/hls"
 )
@@ -17,13 +23,26 @@ type CacheProgress struct {
 type MediaManager struct {
 	URL            string
 	Title          string
-	Items          map[string]*TrackManager
 	FileDownloader *FileDownloader
 	Manifest       *hls.ManifestPlaylist
 	ManifestURL    *url.URL
 	RootDir        string
 	Cache          bool
 	cacheChannel   chan *CacheProgress
+
+	// Dash is the parsed MPD of DASH sources, nil for HLS
+	Dash *dash.Manifest
+
+	// mu guards items and the manifests, which are replaced when the media
+	// is extracted again
+	mu    sync.Mutex
+	items map[string]*TrackManager
+	// loadMu serializes loading tracks, so a track is only loaded once
+	loadMu sync.Mutex
+
+	// reauthMu serializes re-extractions, see RemoteManager.reauthorize
+	reauthMu       sync.Mutex
+	reauthorizedAt time.Time
 }
 
 func (this *MediaManager) StartDownload(mediaType string, index int) error {
@@ -42,6 +61,15 @@ func (this *MediaManager) StopDownload(mediaType string, index int) error {
 	return trackManager.StopDownload()
 }
 
+// RetryFailed downloads again the failed segments of a track
+func (this *MediaManager) RetryFailed(mediaType string, index int) error {
+	trackManager, err := this.GetTrack(context.Background(), mediaType, index)
+	if err != nil {
+		return err
+	}
+	return trackManager.RetryFailed()
+}
+
 func (this *MediaManager) GetDownloadStatus(mediaType string, track int) (*DownloadStatusQeuryResponse, error) {
 	trackManager, err := this.GetTrack(context.Background(), mediaType, track)
 	if err != nil {
@@ -52,7 +80,7 @@ func (this *MediaManager) GetDownloadStatus(mediaType string, track int) (*Downl
 }
 
 func (this *MediaManager) StopAllAndClear() error {
-	for _, item := range this.Items {
+	for _, item := range this.Tracks() {
 		err := item.StopAndClear()
 		if err != nil {
 			return err
@@ -61,9 +89,34 @@ func (this *MediaManager) StopAllAndClear() error {
 	return nil
 }
 
+// Tracks returns the loaded tracks
+func (this *MediaManager) Tracks() []*TrackManager {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return slices.Collect(maps.Values(this.items))
+}
+
+// Playlist returns the current manifest
+func (this *MediaManager) Playlist() *hls.ManifestPlaylist {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.Manifest
+}
+
+func trackKey(trackType string, trackIndex int) string {
+	return fmt.Sprintf("%s_%d", trackType, trackIndex)
+}
+
+func (this *MediaManager) track(key string) *TrackManager {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.items[key]
+}
+
+// GetDuration returns the duration of the stream, or 0 for a live stream
 func (this *MediaManager) GetDuration() float64 {
 	trackManager, err := this.GetTrack(context.Background(), "video", 0)
-	if err != nil {
+	if err != nil || trackManager.Live() {
 		return 0
 	}
 	return trackManager.GetDuration()
@@ -75,16 +128,18 @@ func NewMediaManager(
 	title string,
 	manifestUrl *url.URL,
 	manifest *hls.ManifestPlaylist,
+	dashManifest *dash.Manifest,
 	fileDownloader *FileDownloader,
 	cache bool,
 ) *MediaManager {
 	return &MediaManager{
 		URL:            url,
 		RootDir:        rootDir,
-		Items:          make(map[string]*TrackManager),
+		items:          make(map[string]*TrackManager),
 		FileDownloader: fileDownloader,
 		Manifest:       manifest,
 		ManifestURL:    manifestUrl,
+		Dash:           dashManifest,
 		Title:          title,
 		Cache:          cache,
 	}
@@ -95,22 +150,43 @@ func (this *MediaManager) GetTrack(
 	trackType string,
 	trackIndex int,
 ) (*TrackManager, error) {
-	key := fmt.Sprintf("%s_%d", trackType, trackIndex)
-	if item, exists := this.Items[key]; exists {
+	key := trackKey(trackType, trackIndex)
+	if item := this.track(key); item != nil {
+		return item, nil
+	}
+	this.loadMu.Lock()
+	defer this.loadMu.Unlock()
+	// Another caller may have loaded the track while this one waited
+	if item := this.track(key); item != nil {
 		return item, nil
 	}
 
 	trackResolver := this.createTrackResolver(trackType, trackIndex)
 
 	trackManifest, trackURL, err := trackResolver.GetPlaylist(ctx)
+	// Stale credentials of a history item fail before any segment is read
+	if isAuthFailure(err) && this.FileDownloader.Reauthorize != nil {
+		if err = this.FileDownloader.Reauthorize(ctx); err == nil {
+			trackResolver = this.createTrackResolver(trackType, trackIndex)
+			trackManifest, trackURL, err = trackResolver.GetPlaylist(ctx)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	cacheChannel := make(chan int)
+	// Live segment indexes restart with every load, so segments kept from
+	// an earlier load would be served in place of new ones
+	if trackManifest.Live() {
+		clearRawSegments(filepath.Join(this.RootDir, key))
+	}
+
+	// One pending tick is kept, so the status after the last change is
+	// emitted even while the previous one is still being delivered
+	cacheChannel := make(chan int, 1)
 
 	trackManager := NewTrackManager(
-		*this.FileDownloader,
+		this.FileDownloader,
 		trackManifest,
 		trackURL,
 		filepath.Join(this.RootDir, key),
@@ -120,24 +196,32 @@ func (this *MediaManager) GetTrack(
 		filepath.Join(this.RootDir, key),
 		cacheChannel,
 	)
+	trackManager.Resolver = trackResolver
 
 	go func() {
 		for range cacheChannel {
+			status := trackManager.GetDownloadStatus()
 			events.Emit("download:progress", &DownloadStatus{
-				Status:    trackManager.DownloadStatus,
-				Segments:  trackManager.DownloadedSegments,
+				Status:    status.Status,
+				Segments:  status.Segments,
+				Failed:    status.Failed,
 				URL:       this.URL,
 				MediaType: trackType,
 				Track:     trackIndex,
+				RateLimit: this.FileDownloader.Limiter.Rate(),
 			})
 		}
 	}()
 	
-	this.Items[key] = trackManager
+	this.mu.Lock()
+	this.items[key] = trackManager
+	this.mu.Unlock()
 	return trackManager, nil
 }
 
 func (this *MediaManager) createTrackResolver(trackType string, trackIndex int) *TrackResolver {
+	this.mu.Lock()
+	defer this.mu.Unlock()
 	trackResolver := &TrackResolver{
 		Manifest:         this.Manifest,
 		ManifestURL:      this.ManifestURL,
@@ -145,6 +229,17 @@ func (this *MediaManager) createTrackResolver(trackType string, trackIndex int)
 		TrackIndex:       trackIndex,
 		StorageDirectory: filepath.Join(this.RootDir, fmt.Sprintf("%s_%d", trackType, trackIndex)),
 		FileDownloader:   this.FileDownloader,
+		Dash:             this.Dash,
 	}
 	return trackResolver
 }
+
+// clearRawSegments removes the downloaded segments of a track folder and
+// their failure list
+func clearRawSegments(folder string) {
+	os.Remove(filepath.Join(folder, failedSegmentsFile))
+	matches, _ := filepath.Glob(filepath.Join(folder, "segment_*_raw.ts"))
+	for _, match := range matches {
+		os.Remove(match)
+	}
+}
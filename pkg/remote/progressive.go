@@ -0,0 +1,180 @@
+package remote
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+	"wails-cast/pkg/logger"
+)
+
+// progressiveExtensions are the file types accepted when a server labels
+// media as a generic binary download
+var progressiveExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mkv": true, ".webm": true,
+	".mov": true, ".avi": true, ".ts": true, ".flv": true,
+}
+
+// proxiedHeaders are copied from the source's responses to ffmpeg
+var proxiedHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"}
+
+// ProgressiveSource exposes a plain remote media file (an MP4 or MKV URL) on
+// a loopback URL. ffmpeg reads and seeks in it with range requests, which
+// are forwarded with the captured cookies and headers. The URL holds a random
+// token, so other local programs cannot use the proxy and its credentials.
+type ProgressiveSource struct {
+	URL            *url.URL
+	ContentType    string
+	FileDownloader *FileDownloader
+	client         *http.Client
+	listener       net.Listener
+	server         *http.Server
+	token          string
+	// active counts the requests being served, lastUsed is when the last
+	// one started or ended in Unix nanoseconds
+	active   atomic.Int32
+	lastUsed atomic.Int64
+}
+
+const (
+	// progressiveProbeTimeout bounds probing a URL before it is cast
+	progressiveProbeTimeout = 20 * time.Second
+	// progressiveIdleTimeout is how long a source is kept without requests
+	progressiveIdleTimeout = time.Hour
+)
+
+// IsProgressive probes a URL with HEAD, falling back to a one-byte range
+// request for servers that refuse HEAD, and reports whether it is a media
+// file rather than a page or a streaming manifest. It fails when the source
+// could not be reached or refused both requests.
+func IsProgressive(ctx context.Context, url *url.URL, fileDownloader *FileDownloader) (bool, string, error) {
+	client := &http.Client{Transport: httpClient.Transport, Timeout: 15 * time.Second}
+	resp, err := fileDownloader.do(ctx, client, "HEAD", url, "")
+	if err == nil && resp.StatusCode >= 400 {
+		resp.Body.Close()
+		err = fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	if err != nil {
+		resp, err = fileDownloader.do(ctx, client, "GET", url, "bytes=0-0")
+		if err != nil {
+			return false, "", err
+		}
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return false, "", &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return isProgressiveType(contentType, resp.Request.URL), contentType, nil
+}
+
+// isProgressiveType reports whether a content type is a media file; HLS and
+// DASH manifests and generic binaries without a media extension are not
+func isProgressiveType(contentType string, url *url.URL) bool {
+	switch {
+	case strings.Contains(contentType, "mpegurl"), strings.Contains(contentType, "dash"):
+		return false
+	case strings.HasPrefix(contentType, "video/"), strings.HasPrefix(contentType, "audio/"):
+		return true
+	case contentType == "application/octet-stream", contentType == "binary/octet-stream":
+		return progressiveExtensions[strings.ToLower(path.Ext(url.Path))]
+	}
+	return false
+}
+
+// NewProgressiveSource starts the loopback proxy of a remote media file
+func NewProgressiveSource(url *url.URL, contentType string, fileDownloader *FileDownloader) (*ProgressiveSource, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to create progressive source token: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for progressive source: %w", err)
+	}
+	source := &ProgressiveSource{
+		URL:            url,
+		ContentType:    contentType,
+		FileDownloader: fileDownloader,
+		// No timeout: a response streams for as long as ffmpeg reads it
+		client:   &http.Client{Transport: httpClient.Transport},
+		listener: listener,
+		token:    hex.EncodeToString(token),
+	}
+	source.server = &http.Server{Handler: source, ReadHeaderTimeout: 10 * time.Second}
+	source.lastUsed.Store(time.Now().UnixNano())
+	go source.server.Serve(listener)
+	return source, nil
+}
+
+// LocalURL returns the loopback URL ffmpeg reads the file from. The file name
+// is kept so ffmpeg can guess the format from it.
+func (this *ProgressiveSource) LocalURL() string {
+	return fmt.Sprintf("http://%s/%s/%s", this.listener.Addr(), this.token, url.PathEscape(path.Base(this.URL.Path)))
+}
+
+// Name returns the file name of the source
+func (this *ProgressiveSource) Name() string {
+	name, err := url.PathUnescape(path.Base(this.URL.Path))
+	if err != nil || name == "" || name == "/" || name == "." {
+		return this.URL.Host
+	}
+	return name
+}
+
+// Close stops the loopback proxy and the requests it is serving
+func (this *ProgressiveSource) Close() error {
+	return this.server.Close()
+}
+
+// idle reports whether no request was served for progressiveIdleTimeout
+func (this *ProgressiveSource) idle(now time.Time) bool {
+	return this.active.Load() == 0 && now.Sub(time.Unix(0, this.lastUsed.Load())) > progressiveIdleTimeout
+}
+
+// ServeHTTP forwards a request of ffmpeg, including its Range header, to the
+// remote file
+func (this *ProgressiveSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/"+this.token+"/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	this.active.Add(1)
+	this.lastUsed.Store(time.Now().UnixNano())
+	defer func() {
+		this.lastUsed.Store(time.Now().UnixNano())
+		this.active.Add(-1)
+	}()
+
+	resp, err := this.FileDownloader.do(r.Context(), this.client, r.Method, this.URL, r.Header.Get("Range"))
+	if err != nil {
+		logger.Logger.Warn("Progressive source request failed", "url", this.URL.String(), "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range proxiedHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodGet {
+		io.Copy(w, resp.Body)
+	}
+}
@@ -0,0 +1,55 @@
+package remote
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProgressiveSourceToken(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		io.WriteString(w, "media")
+	}))
+	defer upstream.Close()
+
+	sourceURL, _ := url.Parse(upstream.URL + "/movie.mp4")
+	source, err := NewProgressiveSource(sourceURL, "video/mp4", &FileDownloader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	localURL, _ := url.Parse(source.LocalURL())
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "with the token", path: localURL.Path, status: http.StatusOK},
+		{name: "without the token", path: "/movie.mp4", status: http.StatusNotFound},
+		{name: "with another token", path: "/" + strings.Repeat("0", 32) + "/movie.mp4", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get("http://" + localURL.Host + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+
+	// A closed source no longer accepts connections
+	source.Close()
+	if resp, err := http.Get(source.LocalURL()); err == nil {
+		resp.Body.Close()
+		t.Error("closed source still serves")
+	}
+}
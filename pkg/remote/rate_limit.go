@@ -0,0 +1,121 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateChunk bounds the bytes read at once from a rate limited body, so the
+// limiter throttles smoothly instead of sleeping for whole buffers
+const rateChunk = 16 * 1024
+
+// RateLimiter is a token bucket limiting download throughput. The bucket
+// holds at most one second of traffic.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Bytes per second, 0 = unlimited
+	tokens float64
+	last   time.Time
+}
+
+// globalLimiter throttles all background downloads together
+var globalLimiter = NewRateLimiter(0)
+
+// NewRateLimiter creates a limiter of kbps kilobytes per second (0 =
+// unlimited)
+func NewRateLimiter(kbps int) *RateLimiter {
+	limiter := &RateLimiter{}
+	limiter.SetRate(kbps)
+	return limiter
+}
+
+// SetDownloadRateLimit caps the combined speed of all background downloads
+// in kilobytes per second (0 = unlimited)
+func SetDownloadRateLimit(kbps int) {
+	globalLimiter.SetRate(kbps)
+}
+
+// SetRate changes the limit in kilobytes per second (0 = unlimited)
+func (this *RateLimiter) SetRate(kbps int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.rate = float64(max(kbps, 0) * 1024)
+	this.tokens = this.rate
+	this.last = time.Now()
+}
+
+// Rate returns the limit in kilobytes per second
+func (this *RateLimiter) Rate() int {
+	if this == nil {
+		return 0
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return int(this.rate / 1024)
+}
+
+// wait takes n bytes from the bucket, sleeping until the debt of the
+// readers before is paid off
+func (this *RateLimiter) wait(ctx context.Context, n int) error {
+	this.mu.Lock()
+	if this.rate <= 0 {
+		this.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	this.tokens = min(this.tokens+now.Sub(this.last).Seconds()*this.rate, this.rate)
+	this.last = now
+	this.tokens -= float64(n)
+	var delay time.Duration
+	if this.tokens < 0 {
+		delay = time.Duration(-this.tokens / this.rate * float64(time.Second))
+	}
+	this.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitedReader throttles a response body through limiters
+type rateLimitedReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	limiters []*RateLimiter
+}
+
+func (this *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateChunk {
+		p = p[:rateChunk]
+	}
+	n, err := this.reader.Read(p)
+	for _, limiter := range this.limiters {
+		if waitErr := limiter.wait(this.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type backgroundKey struct{}
+
+// withBackground marks downloads that are not waited for by playback. Only
+// those are rate limited.
+func withBackground(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backgroundKey{}, true)
+}
+
+func isBackground(ctx context.Context) bool {
+	background, _ := ctx.Value(backgroundKey{}).(bool)
+	return background
+}
@@ -0,0 +1,63 @@
+package remote
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		name  string
+		kbps  int
+		reads []int
+		// blocked tells whether the last read has to wait for tokens
+		blocked bool
+	}{
+		{name: "unlimited", kbps: 0, reads: []int{1 << 30}, blocked: false},
+		{name: "negative rate is unlimited", kbps: -5, reads: []int{1 << 30}, blocked: false},
+		{name: "within the bucket", kbps: 1, reads: []int{512, 511}, blocked: false},
+		{name: "whole bucket", kbps: 1, reads: []int{1024}, blocked: false},
+		{name: "beyond the bucket", kbps: 1, reads: []int{1024, 1024}, blocked: true},
+		{name: "single read larger than the bucket", kbps: 1, reads: []int{4096}, blocked: true},
+		{name: "faster rate has a larger bucket", kbps: 8, reads: []int{4096, 4096}, blocked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(tt.kbps)
+			// A canceled context makes wait fail instead of sleeping
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			for _, n := range tt.reads[:len(tt.reads)-1] {
+				if err := limiter.wait(ctx, n); err != nil {
+					t.Fatalf("read of %d bytes blocked: %v", n, err)
+				}
+			}
+			err := limiter.wait(ctx, tt.reads[len(tt.reads)-1])
+			if blocked := err != nil; blocked != tt.blocked {
+				t.Errorf("blocked = %v, want %v", blocked, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRate(t *testing.T) {
+	tests := []struct {
+		kbps int
+		want int
+	}{
+		{kbps: 0, want: 0},
+		{kbps: -1, want: 0},
+		{kbps: 500, want: 500},
+	}
+	for _, tt := range tests {
+		if got := NewRateLimiter(tt.kbps).Rate(); got != tt.want {
+			t.Errorf("NewRateLimiter(%d).Rate() = %d, want %d", tt.kbps, got, tt.want)
+		}
+	}
+
+	var limiter *RateLimiter
+	if got := limiter.Rate(); got != 0 {
+		t.Errorf("nil limiter Rate() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,115 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	u "net/url"
+	"time"
+	"wails-cast/pkg/dash"
+	"wails-cast/pkg/filehelper"
+	"wails-cast/pkg/hls"
+	"wails-cast/pkg/logger"
+)
+
+// reauthorizeInterval is how long a fresh extraction is trusted. Requests
+// refused within it fail instead of extracting again.
+const reauthorizeInterval = time.Minute
+
+// isAuthFailure reports whether the source refused a request because the
+// captured cookies, headers or URL signatures are no longer valid
+func isAuthFailure(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// reauthorize runs the extraction of the original page again and updates
+// the credentials and the manifest of the media in place. Parallel
+// downloads refused at the same time share one extraction.
+func (m *RemoteManager) reauthorize(ctx context.Context, url string, media *MediaManager) error {
+	media.reauthMu.Lock()
+	defer media.reauthMu.Unlock()
+	if time.Since(media.reauthorizedAt) < reauthorizeInterval {
+		return nil
+	}
+
+	logger.Logger.Info("Source refused the cached credentials, extracting again", "url", url)
+	parsed, err := u.Parse(url)
+	if err != nil {
+		return err
+	}
+	previous, _ := filehelper.ReadJson[ExtractionData](extractionFile(url))
+	extractionData, manifest, dashManifest, err := m.doExtraction(parsed)
+	if err != nil {
+		return err
+	}
+	// Settings of the media survive the extraction
+	if previous != nil && previous.RateLimit != 0 {
+		extractionData.RateLimit = previous.RateLimit
+		filehelper.WriteJson(extractionFile(url), extractionData)
+	}
+	manifestURL, err := u.Parse(extractionData.ManifestURL)
+	if err != nil {
+		return err
+	}
+
+	media.FileDownloader.SetCredentials(extractionData.Cookies, extractionData.Headers)
+	media.updateManifest(manifestURL, manifest, dashManifest)
+	media.reauthorizedAt = time.Now()
+	return nil
+}
+
+// updateManifest replaces the manifest of a media and of its track
+// resolvers after an extraction
+func (this *MediaManager) updateManifest(manifestURL *u.URL, manifest *hls.ManifestPlaylist, dashManifest *dash.Manifest) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.ManifestURL = manifestURL
+	this.Manifest = manifest
+	this.Dash = dashManifest
+	for _, item := range this.items {
+		if item.Resolver == nil {
+			continue
+		}
+		item.Resolver.SetManifest(manifestURL, manifest, dashManifest)
+	}
+}
+
+// reauthorize extracts the media again, then reloads the track playlist for
+// its freshly signed segment URLs
+func (this *TrackManager) reauthorize(ctx context.Context) error {
+	if err := this.FileDownloader.Reauthorize(ctx); err != nil {
+		return err
+	}
+	if this.Resolver == nil {
+		return nil
+	}
+	latest, url, err := this.Resolver.RefreshPlaylist(ctx)
+	if err != nil {
+		return err
+	}
+
+	if latest.Live() {
+		this.merge(latest)
+		this.setBaseURL(url)
+		return nil
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	// Segment indexes must keep pointing at the same media
+	if len(latest.Segments) != len(this.Manifest.Segments) {
+		return fmt.Errorf("extracted playlist has %d segments instead of %d", len(latest.Segments), len(this.Manifest.Segments))
+	}
+	this.Manifest = latest
+	this.ManifestURL = url
+	return nil
+}
@@ -1,17 +1,39 @@
 package remote
 
 import (
+	"context"
+	"maps"
+	"net/http"
 	u "net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"wails-cast/pkg/dash"
 	"wails-cast/pkg/extractor"
 	"wails-cast/pkg/filehelper"
 	"wails-cast/pkg/folders"
 	"wails-cast/pkg/hls"
+	"wails-cast/pkg/logger"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 )
 
 type RemoteManager struct {
+	// mu guards items and progressive, which Wails calls and the remote API
+	// share
+	mu    sync.Mutex
 	items map[string]*MediaManager
+	// progressive holds the probed plain media file URLs; nil entries are
+	// URLs that must be extracted
+	progressive map[string]*ProgressiveSource
+	// loads shares the loading of a media, and so its extraction, between
+	// the first calls for a URL
+	loads singleflight.Group
 	Cache bool
 }
 
@@ -21,8 +43,16 @@ type ExtractionData struct {
 	Headers     map[string]string
 	Title       string
 	ManifestURL string
+	// Format is "dash" for MPD sources; empty means HLS
+	Format string
+	// RateLimit caps background downloads of this media in kilobytes per
+	// second (0 = only the global limit)
+	RateLimit int
 }
 
+// FormatDash marks extractions whose manifest is a DASH MPD
+const FormatDash = "dash"
+
 func (m *RemoteManager) GetDownloadStatus(url string, mediaType string, track int) (*DownloadStatusQeuryResponse, error) {
 	media, err := m.GetMedia(url)
 	if err != nil {
@@ -51,48 +81,239 @@ func (m *RemoteManager) StartDownload(url string, mediaType string, index int) e
 	return media.StartDownload(mediaType, index)
 }
 
+// TrackStatus returns the download status of a track that is already
+// loaded, without loading it
+func (m *RemoteManager) TrackStatus(url string, mediaType string, index int) (*DownloadStatusQeuryResponse, bool) {
+	m.mu.Lock()
+	media, exists := m.items[url]
+	m.mu.Unlock()
+	if !exists {
+		return nil, false
+	}
+	track := media.track(trackKey(mediaType, index))
+	if track == nil {
+		return nil, false
+	}
+	return track.GetDownloadStatus(), true
+}
+
+// RetryFailed downloads again the failed segments of a track
+func (m *RemoteManager) RetryFailed(url string, mediaType string, index int) error {
+	media, err := m.GetMedia(url)
+	if err != nil {
+		return err
+	}
+	return media.RetryFailed(mediaType, index)
+}
+
+// SetRateLimit caps the background downloads of a media in kilobytes per
+// second (0 = only the global limit). The limit is kept with the extraction.
+func (m *RemoteManager) SetRateLimit(url string, kbps int) error {
+	media, err := m.GetMedia(url)
+	if err != nil {
+		return err
+	}
+	media.FileDownloader.Limiter.SetRate(kbps)
+	for _, item := range media.Tracks() {
+		item.statusUpdate()
+	}
+
+	extractionData, err := filehelper.ReadJson[ExtractionData](extractionFile(url))
+	if err != nil {
+		return err
+	}
+	extractionData.RateLimit = kbps
+	return filehelper.WriteJson(extractionFile(url), extractionData)
+}
+
 // NewManager creates a new remote manager
 func NewManager(cache bool) *RemoteManager {
 	return &RemoteManager{
-		items: make(map[string]*MediaManager),
-		Cache: cache,
+		items:       make(map[string]*MediaManager),
+		progressive: make(map[string]*ProgressiveSource),
+		Cache:       cache,
 	}
 }
 
+// Medias returns the loaded medias
+func (m *RemoteManager) Medias() []*MediaManager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Collect(maps.Values(m.items))
+}
+
 func (m *RemoteManager) StopAllAndClear() error {
-	for _, item := range m.items {
+	for _, item := range m.Medias() {
 		err := item.StopAllAndClear()
 		if err != nil {
 			return err
 		}
 	}
+
+	// Plain media files are probed again when next cast
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for url, source := range m.progressive {
+		if source != nil {
+			source.Close()
+		}
+		delete(m.progressive, url)
+	}
 	return nil
 }
 
-func (m *RemoteManager) GetMedia(url string) (*MediaManager, error) {
+// GetProgressive returns the source of a URL pointing at a plain media file
+// such as an MP4 or MKV, or nil when the URL is a page to extract a stream
+// from. The answer of a reachable source is remembered; a failed probe is
+// tried again on the next call. A source that cannot be reached is still
+// taken for a media file by its extension, and fails otherwise rather than
+// being extracted as a page.
+func (m *RemoteManager) GetProgressive(url string) (*ProgressiveSource, error) {
+	m.mu.Lock()
+	m.evictIdleProgressive(time.Now())
+	source, probed := m.progressive[url]
+	m.mu.Unlock()
+	if probed {
+		return source, nil
+	}
 	parsed, err := u.Parse(url)
 	if err != nil {
 		return nil, err
 	}
-	if item, exists := m.items[url]; exists {
+
+	// Pages extracted before are known not to be media files
+	if !filehelper.Exists(extractionFile(url)) {
+		ctx, cancel := context.WithTimeout(context.Background(), progressiveProbeTimeout)
+		defer cancel()
+		fileDownloader := siteCredentials(parsed)
+		progressive, contentType, err := IsProgressive(ctx, parsed, fileDownloader)
+		var statusErr *StatusError
+		switch {
+		case err == nil:
+		case progressiveExtensions[strings.ToLower(path.Ext(parsed.Path))]:
+			logger.Logger.Warn("Failed to probe URL, taking it for a media file", "url", url, "error", err)
+			progressive = true
+		case errors.As(err, &statusErr) && statusErr.StatusCode < 500 && statusErr.StatusCode != http.StatusTooManyRequests:
+			// The server refused the probe, as pages often do for clients
+			// other than browsers. Not remembered, like any failed probe.
+			logger.Logger.Warn("Failed to probe URL", "url", url, "error", err)
+			return nil, nil
+		default:
+			return nil, errors.Wrapf(err, "failed to probe %s", url)
+		}
+		if progressive {
+			source, err = NewProgressiveSource(parsed, contentType, fileDownloader)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A concurrent call may have probed the URL meanwhile
+	if existing, probed := m.progressive[url]; probed {
+		if source != nil {
+			source.Close()
+		}
+		return existing, nil
+	}
+	m.progressive[url] = source
+	return source, nil
+}
+
+// evictIdleProgressive stops the sources that served no request for
+// progressiveIdleTimeout; m.mu must be held
+func (m *RemoteManager) evictIdleProgressive(now time.Time) {
+	for url, source := range m.progressive {
+		if source != nil && source.idle(now) {
+			source.Close()
+			delete(m.progressive, url)
+		}
+	}
+}
+
+// siteCredentials returns a downloader with the cookies and headers of the
+// latest extraction on the host of url, so a file linked from an extracted
+// site is requested like the site's streams
+func siteCredentials(url *u.URL) *FileDownloader {
+	matches, _ := filepath.Glob(filepath.Join(folders.Cache(), "*", "extraction.json"))
+	var latest *ExtractionData
+	var latestTime time.Time
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || !info.ModTime().After(latestTime) {
+			continue
+		}
+		extractionData, err := filehelper.ReadJson[ExtractionData](match)
+		if err != nil || !sameHost(extractionData.URL, url) && !sameHost(extractionData.ManifestURL, url) {
+			continue
+		}
+		latest, latestTime = extractionData, info.ModTime()
+	}
+	if latest == nil {
+		return &FileDownloader{}
+	}
+	return &FileDownloader{Cookies: latest.Cookies, Headers: latest.Headers}
+}
+
+func sameHost(rawURL string, url *u.URL) bool {
+	parsed, err := u.Parse(rawURL)
+	return err == nil && parsed.Host == url.Host
+}
+
+func (m *RemoteManager) GetMedia(url string) (*MediaManager, error) {
+	m.mu.Lock()
+	item, exists := m.items[url]
+	m.mu.Unlock()
+	if exists {
 		return item, nil
 	}
+
+	result, err, _ := m.loads.Do(url, func() (any, error) {
+		return m.loadMedia(url)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*MediaManager), nil
+}
+
+// loadMedia reads the manifest of a URL, extracting it from the page first
+// when it was never extracted, and keeps the media
+func (m *RemoteManager) loadMedia(url string) (*MediaManager, error) {
+	parsed, err := u.Parse(url)
+	if err != nil {
+		return nil, err
+	}
 	extractionFile := extractionFile(url)
 	extractionData, err := filehelper.ReadJson[ExtractionData](extractionFile)
 
 	var manifest *hls.ManifestPlaylist
+	var dashManifest *dash.Manifest
 	if err != nil {
-		extractionData, manifest, err = m.doExtraction(parsed)
+		extractionData, manifest, dashManifest, err = m.doExtraction(parsed)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	manifestUrl, err := u.Parse(extractionData.ManifestURL)
 	if err != nil {
 		return nil, err
 	}
 
-	if manifest == nil {
+	if manifest == nil && extractionData.Format == FormatDash {
+		bytes, err := os.ReadFile(dashFile(url))
+		if err != nil {
+			return nil, err
+		}
+		dashManifest, err = dash.Parse(string(bytes), manifestUrl)
+		if err != nil {
+			return nil, err
+		}
+		manifest = dashManifest.Playlist
+	} else if manifest == nil {
 		bytes, err := os.ReadFile(hlsFile(url))
 		if err != nil {
 			return nil, err
@@ -103,22 +324,31 @@ func (m *RemoteManager) GetMedia(url string) (*MediaManager, error) {
 		}
 	}
 
-	manifestUrl, err := u.Parse(extractionData.ManifestURL)
-	if err != nil {
-		return nil, err
-	}
 	mediaItem := NewMediaManager(
 		url,
 		folders.Video(url),
 		extractionData.Title,
 		manifestUrl,
 		manifest,
+		dashManifest,
 		&FileDownloader{
 			Cookies: extractionData.Cookies,
 			Headers: extractionData.Headers,
+			Limiter: NewRateLimiter(extractionData.RateLimit),
 		},
 		m.Cache,
 	)
+	mediaItem.FileDownloader.Reauthorize = func(ctx context.Context) error {
+		return m.reauthorize(ctx, url, mediaItem)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A call that started as the previous load ended may have loaded the
+	// media meanwhile; it has no tracks yet, so this one is simply dropped
+	if item, exists := m.items[url]; exists {
+		return item, nil
+	}
 	m.items[url] = mediaItem
 	return mediaItem, nil
 }
@@ -127,18 +357,11 @@ func extractionFile(url string) string {
 	return filepath.Join(folders.Video(url), "extraction.json")
 }
 
-func (*RemoteManager) doExtraction(url *u.URL) (*ExtractionData, *hls.ManifestPlaylist, error) {
+func (*RemoteManager) doExtraction(url *u.URL) (*ExtractionData, *hls.ManifestPlaylist, *dash.Manifest, error) {
 	extraction, err := extractor.Extract(url.String())
 	if err != nil {
-		return nil, nil, err
-	}
-
-	manifest, err := hls.ParseManifest(extraction.Manifest)
-	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	hlsFile := hlsFile(url.String())
-	filehelper.WriteFile(hlsFile, []byte(extraction.Manifest))
 
 	extractionData := &ExtractionData{
 		URL:         url.String(),
@@ -148,8 +371,30 @@ func (*RemoteManager) doExtraction(url *u.URL) (*ExtractionData, *hls.ManifestPl
 		ManifestURL: url.ResolveReference(extraction.URL).String(),
 	}
 
+	var manifest *hls.ManifestPlaylist
+	var dashManifest *dash.Manifest
+	if dash.IsManifest(extraction.Manifest) {
+		manifestUrl, err := u.Parse(extractionData.ManifestURL)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		dashManifest, err = dash.Parse(extraction.Manifest, manifestUrl)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		manifest = dashManifest.Playlist
+		extractionData.Format = FormatDash
+		filehelper.WriteFile(dashFile(url.String()), []byte(extraction.Manifest))
+	} else {
+		manifest, err = hls.ParseManifest(extraction.Manifest)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		filehelper.WriteFile(hlsFile(url.String()), []byte(extraction.Manifest))
+	}
+
 	filehelper.WriteJson(extractionFile(url.String()), extractionData)
-	return extractionData, manifest, nil
+	return extractionData, manifest, dashManifest, nil
 }
 
 func hlsFile(url string) string {
@@ -157,3 +402,7 @@ func hlsFile(url string) string {
 	hlsFile := filepath.Join(videoFolder, "playlist.m3u8")
 	return hlsFile
 }
+
+func dashFile(url string) string {
+	return filepath.Join(folders.Video(url), "manifest.mpd")
+}
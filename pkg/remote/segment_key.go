@@ -0,0 +1,153 @@
+package remote
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"wails-cast/pkg/ffmpeg"
+	"wails-cast/pkg/hls"
+
+	"github.com/pkg/errors"
+)
+
+// HLS encryption methods (#EXT-X-KEY METHOD)
+const (
+	keyMethodNone      = "NONE"
+	keyMethodAES128    = "AES-128"
+	keyMethodSampleAES = "SAMPLE-AES"
+)
+
+// decryptSegment returns the clear data of a downloaded segment. AES-128
+// segments are decrypted whole, SAMPLE-AES segments sample by sample.
+func (this *TrackManager) decryptSegment(ctx context.Context, playlist *hls.TrackPlaylist, segmentIndex int, data []byte) ([]byte, error) {
+	key := playlist.Segments[segmentIndex].Key
+	if key == nil || key.Method == "" || key.Method == keyMethodNone {
+		return data, nil
+	}
+	if key.KeyFormat != "" && key.KeyFormat != "identity" {
+		return nil, fmt.Errorf("unsupported key format %s (DRM protected stream)", key.KeyFormat)
+	}
+
+	keyData, err := this.getKey(ctx, key.URI)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := segmentIV(key, playlist.MediaSequence+segmentIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.Method {
+	case keyMethodAES128:
+		return decryptAES128(data, keyData, iv)
+	case keyMethodSampleAES:
+		return ffmpeg.DecryptSampleAES(ctx, data, keyData, iv)
+	}
+	return nil, fmt.Errorf("unsupported encryption method %s", key.Method)
+}
+
+// getKey fetches a key with the captured cookies and headers, caching it for
+// the segments that share it. Segments waiting for the same key share one
+// fetch, and other keys are not held up by it.
+func (this *TrackManager) getKey(ctx context.Context, uri string) ([]byte, error) {
+	this.keyMu.Lock()
+	key, found := this.keys[uri]
+	this.keyMu.Unlock()
+	if found {
+		return key, nil
+	}
+
+	result, err, _ := this.keyFetches.Do(uri, func() (any, error) {
+		key, err := this.fetchKey(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		this.keyMu.Lock()
+		defer this.keyMu.Unlock()
+		if this.keys == nil {
+			this.keys = make(map[string][]byte)
+		}
+		this.keys[uri] = key
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]byte), nil
+}
+
+// fetchKey decodes an inline key or downloads it
+func (this *TrackManager) fetchKey(ctx context.Context, uri string) ([]byte, error) {
+	var key []byte
+	if strings.HasPrefix(uri, "data:") {
+		// data:text/plain;base64,<key>
+		_, encoded, _ := strings.Cut(uri, ",")
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode inline key")
+		}
+		key = decoded
+	} else {
+		keyURL, err := url.Parse(uri)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse key URI: %s", uri)
+		}
+		keyURL = this.baseURL().ResolveReference(keyURL)
+		key, err = this.FileDownloader.DownloadFile(ctx, keyURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to download key: %s", keyURL)
+		}
+	}
+
+	if len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid key length %d", len(key))
+	}
+	return key, nil
+}
+
+// segmentIV returns the explicit IV of a key, or the segment's media
+// sequence number as a big-endian 128-bit integer
+func segmentIV(key *hls.Key, sequence int) ([]byte, error) {
+	if key.IV != "" {
+		iv, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(key.IV, "0x"), "0X"))
+		if err != nil || len(iv) != aes.BlockSize {
+			return nil, fmt.Errorf("invalid IV %s", key.IV)
+		}
+		return iv, nil
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(sequence))
+	return iv, nil
+}
+
+// decryptAES128 decrypts an AES-128-CBC segment and removes its PKCS#7 padding
+func decryptAES128(data []byte, key []byte, iv []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted segment size %d is not a multiple of the block size", len(data))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, fmt.Errorf("invalid padding, wrong key?")
+	}
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, fmt.Errorf("invalid padding, wrong key?")
+		}
+	}
+	return plain[:len(plain)-padding], nil
+}
@@ -0,0 +1,92 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"wails-cast/pkg/hls"
+)
+
+// encryptAES128 encrypts data as is, the caller adds the padding
+func encryptAES128(t *testing.T, data []byte, key []byte, iv []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, data)
+	return encrypted
+}
+
+// pkcs7 pads data to a whole number of blocks
+func pkcs7(data []byte) []byte {
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	return append(bytes.Clone(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+func TestDecryptAES128(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := []byte("fedcba9876543210")
+	payload := bytes.Repeat([]byte("segment "), 8)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		key     []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "one byte", data: encryptAES128(t, pkcs7(payload[:1]), key, iv), key: key, want: payload[:1]},
+		{name: "partial block", data: encryptAES128(t, pkcs7(payload[:15]), key, iv), key: key, want: payload[:15]},
+		{name: "whole block adds a padding block", data: encryptAES128(t, pkcs7(payload[:16]), key, iv), key: key, want: payload[:16]},
+		{name: "several blocks", data: encryptAES128(t, pkcs7(payload[:41]), key, iv), key: key, want: payload[:41]},
+		{name: "empty payload", data: encryptAES128(t, pkcs7(nil), key, iv), key: key, want: []byte{}},
+		{name: "no data", data: nil, key: key, wantErr: true},
+		{name: "truncated", data: encryptAES128(t, pkcs7(payload[:20]), key, iv)[:20], key: key, wantErr: true},
+		{name: "zero padding", data: encryptAES128(t, append(bytes.Clone(payload[:15]), 0), key, iv), key: key, wantErr: true},
+		{name: "inconsistent padding", data: encryptAES128(t, append(bytes.Clone(payload[:13]), 1, 3, 3), key, iv), key: key, wantErr: true},
+		{name: "padding larger than a block", data: encryptAES128(t, append(bytes.Clone(payload[:15]), 17), key, iv), key: key, wantErr: true},
+		{name: "invalid key length", data: encryptAES128(t, pkcs7(payload[:4]), key, iv), key: key[:10], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptAES128(tt.data, tt.key, iv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("decrypted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentIV(t *testing.T) {
+	tests := []struct {
+		name     string
+		iv       string
+		sequence int
+		want     []byte
+		wantErr  bool
+	}{
+		{name: "media sequence", sequence: 0x0102, want: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}},
+		{name: "explicit", iv: "0x000102030405060708090A0B0C0D0E0F", sequence: 7, want: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}},
+		{name: "uppercase prefix", iv: "0X0000000000000000000000000000000F", want: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 15}},
+		{name: "too short", iv: "0x0102", wantErr: true},
+		{name: "not hexadecimal", iv: "0xZZ0102030405060708090A0B0C0D0E0F", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := segmentIV(&hls.Key{Method: keyMethodAES128, IV: tt.iv}, tt.sequence)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("IV = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,15 +6,19 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 	"wails-cast/pkg/filehelper"
 	"wails-cast/pkg/hls"
 	"wails-cast/pkg/mix"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 )
 
 type TrackManager struct {
-	FileDownloader     FileDownloader
+	FileDownloader     *FileDownloader
 	cacheChannel       chan int
 	Manifest           *hls.TrackPlaylist
 	ManifestURL        *url.URL
@@ -23,74 +27,103 @@ type TrackManager struct {
 	TrackType          string
 	TrackIndex         int
 	StorageDirectory   string
-	DownloadStatus     string
-	DownloadedSegments []bool
-	cancelDownload     context.CancelFunc
+	downloadedSegments []bool
+
+	// statusMu guards the download status and the cancel function of the
+	// running download, see Status
+	statusMu       sync.Mutex
+	downloadStatus string
+	cancelDownload context.CancelFunc
+
+	// Resolver reloads live playlists, see Refresh
+	Resolver *TrackResolver
+	// LoadedAt anchors the program date times of the served segments
+	LoadedAt time.Time
+
+	// mu guards Manifest, downloadedSegments and the live window. Live
+	// playlists are replaced on every refresh, never modified.
+	mu           sync.RWMutex
+	windowStart  int
+	nextSequence int
+	refreshing   bool
+	lastPoll     time.Time
+
+	// keys caches the #EXT-X-KEY keys by URI, see getKey
+	keyMu      sync.Mutex
+	keys       map[string][]byte
+	keyFetches singleflight.Group
+	// initSegments caches the #EXT-X-MAP init sections, see getInitSegment
+	initMu       sync.Mutex
+	initSegments map[string][]byte
+	initFetches  singleflight.Group
+	// failedSegments maps segments whose download failed to the error, see
+	// RetryFailed
+	failedMu       sync.Mutex
+	failedSegments map[int]string
 }
 
 type DownloadStatusQeuryResponse struct {
 	Status   string
 	Segments []bool
+	Failed   []int
 }
 
 func (this *TrackManager) StopDownload() error {
+	this.statusMu.Lock()
 	if this.cancelDownload != nil {
 		this.cancelDownload()
 		this.cancelDownload = nil
 	}
-	this.DownloadStatus = "STOPPED"
+	this.downloadStatus = "STOPPED"
+	this.statusMu.Unlock()
 	this.statusUpdate()
 	return nil
 }
 
-func (this *TrackManager) StartDownload() error {
-	if this.DownloadStatus == "INPROGRESS" {
-		return nil
+// GetDownloadStatus returns a snapshot of the download progress
+func (this *TrackManager) GetDownloadStatus() *DownloadStatusQeuryResponse {
+	return &DownloadStatusQeuryResponse{
+		Status:   this.Status(),
+		Segments: this.Segments(),
+		Failed:   this.FailedSegments(),
 	}
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	this.cancelDownload = cancel
-	this.DownloadStatus = "INPROGRESS"
+// Status returns the download status: IDLE, INPROGRESS, SCHEDULED, STOPPED,
+// COMPLETED or ERROR
+func (this *TrackManager) Status() string {
+	this.statusMu.Lock()
+	defer this.statusMu.Unlock()
+	return this.downloadStatus
+}
+
+func (this *TrackManager) setStatus(status string) {
+	this.statusMu.Lock()
+	this.downloadStatus = status
+	this.statusMu.Unlock()
 	this.statusUpdate()
+}
 
-	go func() {
-		defer cancel()
-		for i := range this.Manifest.Segments {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				if this.DownloadedSegments[i] {
-					continue
-				}
-
-				_, err := this.GetSegment(ctx, i)
-				if err != nil {
-					// Check if it was canceled
-					select {
-					case <-ctx.Done():
-						return
-					default:
-						// Log error but continue or stop? Let's stop for now on real errors
-						this.DownloadStatus = "ERROR"
-						this.statusUpdate()
-						return
-					}
-				}
-			}
-		}
-		this.DownloadStatus = "COMPLETED"
+// switchStatus changes the status only while it is from, so a stopped
+// download is not reported running again
+func (this *TrackManager) switchStatus(from string, to string) bool {
+	this.statusMu.Lock()
+	switched := this.downloadStatus == from
+	if switched {
+		this.downloadStatus = to
+	}
+	this.statusMu.Unlock()
+	if switched {
 		this.statusUpdate()
-	}()
-
-	return nil
+	}
+	return switched
 }
 
-func (this *TrackManager) GetDownloadStatus() *DownloadStatusQeuryResponse {
-	return &DownloadStatusQeuryResponse{
-		Status:   this.DownloadStatus,
-		Segments: this.DownloadedSegments,
-	}
+// Segments returns a copy of the downloaded flags of the segments
+func (this *TrackManager) Segments() []bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return slices.Clone(this.downloadedSegments)
 }
 
 func (this *TrackManager) StopAndClear() error {
@@ -104,22 +137,24 @@ func (this *TrackManager) StopAndClear() error {
 		return errors.Wrapf(err, "failed to recreate folder: %s", this.Folder)
 	}
 
-	this.DownloadedSegments = make([]bool, len(this.Manifest.Segments))
-	this.DownloadStatus = "IDLE"
-	this.statusUpdate()
+	this.mu.Lock()
+	this.downloadedSegments = make([]bool, len(this.Manifest.Segments))
+	this.mu.Unlock()
+	this.clearFailed()
+	this.setStatus("IDLE")
 	return nil
 }
 
 func (this *TrackManager) GetDuration() float64 {
 	var totalDuration float64
-	for _, segment := range this.Manifest.Segments {
+	for _, segment := range this.Playlist().Segments {
 		totalDuration += segment.Duration
 	}
 	return totalDuration
 }
 
 func NewTrackManager(
-	fileDownloader FileDownloader,
+	fileDownloader *FileDownloader,
 	manifest *hls.TrackPlaylist,
 	manifestURL *url.URL,
 	folder string,
@@ -141,6 +176,10 @@ func NewTrackManager(
 	if allDownloaded {
 		status = "COMPLETED"
 	}
+	failed := loadFailedSegments(folder)
+	if len(failed) > 0 {
+		status = "ERROR"
+	}
 
 	return &TrackManager{
 		FileDownloader:     fileDownloader,
@@ -151,9 +190,12 @@ func NewTrackManager(
 		TrackType:          trackType,
 		TrackIndex:         trackIndex,
 		StorageDirectory:   storageDirectory,
-		DownloadedSegments: downloaded,
+		downloadedSegments: downloaded,
 		cacheChannel:       cacheChannel,
-		DownloadStatus:     status,
+		downloadStatus:     status,
+		LoadedAt:           time.Now(),
+		failedSegments:     failed,
+		nextSequence:       manifest.MediaSequence + len(manifest.Segments),
 	}
 }
 
@@ -168,22 +210,102 @@ func computeDownloadedSegments(folder string, total int) []bool {
 	return downloaded
 }
 
+// downloadSegment downloads a segment, extracting the media again once when
+// the source refuses the request because its credentials expired
 func (this *TrackManager) downloadSegment(ctx context.Context, segmentIndex int) ([]byte, error) {
-	url, err := this.resolveSegmentURL(segmentIndex)
+	data, err := this.requestSegment(ctx, segmentIndex)
+	if err == nil || !isAuthFailure(err) || this.FileDownloader.Reauthorize == nil {
+		return data, err
+	}
+	if reauthErr := this.reauthorize(ctx); reauthErr != nil {
+		return nil, errors.Wrapf(reauthErr, "failed to re-extract after: %v", err)
+	}
+	return this.requestSegment(ctx, segmentIndex)
+}
+
+func (this *TrackManager) requestSegment(ctx context.Context, segmentIndex int) ([]byte, error) {
+	playlist := this.Playlist()
+	if segmentIndex < 0 || segmentIndex >= len(playlist.Segments) {
+		return nil, fmt.Errorf("segment %d is not in the playlist", segmentIndex)
+	}
+	url, err := this.resolveSegmentURL(playlist, segmentIndex)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to resolve segment URL for index: %d", segmentIndex)
 	}
-	data, err := this.FileDownloader.DownloadFile(ctx, url)
+	segment := playlist.Segments[segmentIndex]
+	data, err := this.download(ctx, url, segment.ByteRange)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to download segment: %s", url)
 	}
 
+	data, err = this.decryptSegment(ctx, playlist, segmentIndex, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt segment: %s", url)
+	}
+
+	// fMP4 fragments cannot be demuxed without their init section, so it
+	// is prepended to make every stored segment self-contained
+	if segment.Map != nil {
+		init, err := this.getInitSegment(ctx, segment.Map)
+		if err != nil {
+			return nil, err
+		}
+		data = append(init[:len(init):len(init)], data...)
+	}
+
 	return data, nil
 }
 
-func (this *TrackManager) resolveSegmentURL(segmentIndex int) (*url.URL, error) {
-	segment := this.Manifest.Segments[segmentIndex]
-	resolvedUrl := this.ManifestURL.ResolveReference(segment.URI)
+// download fetches a whole file, or only the given byte range of it
+func (this *TrackManager) download(ctx context.Context, url *url.URL, byteRange *hls.ByteRange) ([]byte, error) {
+	if byteRange != nil {
+		return this.FileDownloader.DownloadRange(ctx, url, byteRange.Offset, byteRange.Length)
+	}
+	return this.FileDownloader.DownloadFile(ctx, url)
+}
+
+// getInitSegment downloads an #EXT-X-MAP init section once per track, like
+// getKey outside the cache lock
+func (this *TrackManager) getInitSegment(ctx context.Context, initMap *hls.Map) ([]byte, error) {
+	key := initMap.URI
+	if initMap.ByteRange != nil {
+		key = fmt.Sprintf("%s@%d-%d", key, initMap.ByteRange.Offset, initMap.ByteRange.Length)
+	}
+	this.initMu.Lock()
+	data, found := this.initSegments[key]
+	this.initMu.Unlock()
+	if found {
+		return data, nil
+	}
+
+	result, err, _ := this.initFetches.Do(key, func() (any, error) {
+		uri, err := url.Parse(initMap.URI)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse init segment URI: %s", initMap.URI)
+		}
+		uri = this.baseURL().ResolveReference(uri)
+		data, err := this.download(ctx, uri, initMap.ByteRange)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to download init segment: %s", uri)
+		}
+
+		this.initMu.Lock()
+		defer this.initMu.Unlock()
+		if this.initSegments == nil {
+			this.initSegments = make(map[string][]byte)
+		}
+		this.initSegments[key] = data
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]byte), nil
+}
+
+func (this *TrackManager) resolveSegmentURL(playlist *hls.TrackPlaylist, segmentIndex int) (*url.URL, error) {
+	segment := playlist.Segments[segmentIndex]
+	resolvedUrl := this.baseURL().ResolveReference(segment.URI)
 	return resolvedUrl, nil
 }
 
@@ -204,12 +326,26 @@ func (this *TrackManager) GetSegment(ctx context.Context, segmentIndex int) (*mi
 	}
 
 	if filehelper.WriteFile(cachePath, data) == nil {
-		this.DownloadedSegments[segmentIndex] = true
+		this.markDownloaded(segmentIndex)
 		this.statusUpdate()
 	}
 	return mix.File(cachePath), nil
 }
 
+func (this *TrackManager) isDownloaded(segmentIndex int) bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.downloadedSegments[segmentIndex]
+}
+
+func (this *TrackManager) markDownloaded(segmentIndex int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.downloadedSegments[segmentIndex] = true
+}
+
+// statusUpdate asks for the status to be emitted. Updates coalesce into the
+// pending one, which reads the status when it is emitted.
 func (this *TrackManager) statusUpdate() {
 	select {
 	case this.cacheChannel <- 0:
@@ -220,3 +356,16 @@ func (this *TrackManager) statusUpdate() {
 func getSegmentPath(folder string, segmentIndex int) string {
 	return filepath.Join(folder, fmt.Sprintf("segment_%d_raw.ts", segmentIndex))
 }
+
+// baseURL returns the URL segment and key URIs are relative to
+func (this *TrackManager) baseURL() *url.URL {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.ManifestURL
+}
+
+func (this *TrackManager) setBaseURL(url *url.URL) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.ManifestURL = url
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"sync"
+	"time"
 	"wails-cast/pkg/cache"
+	"wails-cast/pkg/dash"
+	"wails-cast/pkg/filehelper"
 	"wails-cast/pkg/hls"
 )
 
@@ -19,40 +23,72 @@ type TrackResolver struct {
 	TrackType        string
 	TrackIndex       int
 	StorageDirectory string
+	// Dash is set for DASH sources, whose track playlists are built from
+	// the MPD instead of downloaded
+	Dash *dash.Manifest
+
+	// mu guards Manifest, ManifestURL and Dash, which are replaced after an
+	// extraction, see SetManifest
+	mu sync.RWMutex
+}
+
+// SetManifest replaces the manifest the track playlist is resolved from
+func (this *TrackResolver) SetManifest(manifestURL *url.URL, manifest *hls.ManifestPlaylist, dashManifest *dash.Manifest) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.ManifestURL = manifestURL
+	this.Manifest = manifest
+	this.Dash = dashManifest
+}
+
+// dashManifest returns the current MPD of DASH sources, nil otherwise
+func (this *TrackResolver) dashManifest() *dash.Manifest {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.Dash
 }
 
 func (this *TrackResolver) trackUrl() (*url.URL, error) {
+	this.mu.RLock()
+	manifest, manifestURL := this.Manifest, this.ManifestURL
+	this.mu.RUnlock()
+
 	var url *url.URL
 	switch this.TrackType {
 	case "video":
-		if this.TrackIndex < 0 || this.TrackIndex >= len(this.Manifest.VideoTracks) {
+		if this.TrackIndex < 0 || this.TrackIndex >= len(manifest.VideoTracks) {
 			return nil, fmt.Errorf("video track index out of range")
 		}
-		url = this.Manifest.VideoTracks[this.TrackIndex].URI
+		url = manifest.VideoTracks[this.TrackIndex].URI
 	case "audio":
-		if this.TrackIndex < 0 || this.TrackIndex >= len(this.Manifest.AudioTracks) {
+		if this.TrackIndex < 0 || this.TrackIndex >= len(manifest.AudioTracks) {
 			return nil, fmt.Errorf("audio track index out of range")
 		}
-		url = this.Manifest.AudioTracks[this.TrackIndex].URI
+		url = manifest.AudioTracks[this.TrackIndex].URI
 	case "subtitle":
-		if this.TrackIndex < 0 || this.TrackIndex >= len(this.Manifest.SubtitleTracks) {
+		if this.TrackIndex < 0 || this.TrackIndex >= len(manifest.SubtitleTracks) {
 			return nil, fmt.Errorf("subtitle track index out of range")
 		}
-		url = this.Manifest.SubtitleTracks[this.TrackIndex].URI
+		url = manifest.SubtitleTracks[this.TrackIndex].URI
 	default:
 		return nil, nil
 	}
-	return this.ManifestURL.ResolveReference(url), nil
+	return manifestURL.ResolveReference(url), nil
 }
 
 func (this *TrackResolver) GetPlaylist(ctx context.Context) (*hls.TrackPlaylist, *url.URL, error) {
+	if dashManifest := this.dashManifest(); dashManifest != nil {
+		return this.dashPlaylist(ctx, dashManifest)
+	}
 	url, err := this.trackUrl()
 	if err != nil {
 		return nil, nil, err
 	}
+	downloaded := false
 	data, err := cache.Get(
-		filepath.Join(this.StorageDirectory, "playlist.m3u8"),
+		this.playlistPath(),
 		func() ([]byte, error) {
+			downloaded = true
 			return this.FileDownloader.DownloadFile(ctx, url)
 		},
 	)
@@ -63,5 +99,98 @@ func (this *TrackResolver) GetPlaylist(ctx context.Context) (*hls.TrackPlaylist,
 	if err != nil {
 		return nil, nil, err
 	}
+	// A cached live playlist is stale as soon as its target duration passed
+	if playlist.Live() && !downloaded {
+		return this.RefreshPlaylist(ctx)
+	}
+	return playlist, url, nil
+}
+
+// RefreshPlaylist downloads the current version of a track playlist,
+// replacing the cached copy. Live playlists are refreshed periodically, others
+// after the media was extracted again.
+func (this *TrackResolver) RefreshPlaylist(ctx context.Context) (*hls.TrackPlaylist, *url.URL, error) {
+	if dashManifest := this.dashManifest(); dashManifest != nil {
+		return this.refreshDashPlaylist(ctx, dashManifest)
+	}
+	url, err := this.trackUrl()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := this.FileDownloader.DownloadFile(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+	playlist, err := hls.ParseTrackPlaylist(string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	filehelper.WriteFile(this.playlistPath(), data)
+	return playlist, url, nil
+}
+
+func (this *TrackResolver) playlistPath() string {
+	return filepath.Join(this.StorageDirectory, "playlist.m3u8")
+}
+
+// dashPlaylist builds the track playlist of a DASH representation. Static
+// presentations are cached, since SegmentBase tracks download their index.
+func (this *TrackResolver) dashPlaylist(ctx context.Context, dashManifest *dash.Manifest) (*hls.TrackPlaylist, *url.URL, error) {
+	if dashManifest.MPD.Dynamic() {
+		return this.buildDashPlaylist(ctx, dashManifest)
+	}
+	data, err := cache.Get(
+		this.playlistPath(),
+		func() ([]byte, error) {
+			playlist, _, err := this.buildDashPlaylist(ctx, dashManifest)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(playlist.Generate()), nil
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	playlist, err := hls.ParseTrackPlaylist(string(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	return playlist, dashManifest.URL, nil
+}
+
+// refreshDashPlaylist reloads a dynamic MPD and rebuilds the track playlist
+func (this *TrackResolver) refreshDashPlaylist(ctx context.Context, previous *dash.Manifest) (*hls.TrackPlaylist, *url.URL, error) {
+	data, err := this.FileDownloader.DownloadFile(ctx, previous.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := dash.Parse(string(data), previous.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	// An extraction running meanwhile brought a newer MPD, keep it
+	this.mu.Lock()
+	if this.Dash == previous {
+		this.Dash = manifest
+	}
+	this.mu.Unlock()
+	playlist, url, err := this.buildDashPlaylist(ctx, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !manifest.MPD.Dynamic() {
+		filehelper.WriteFile(this.playlistPath(), []byte(playlist.Generate()))
+	}
 	return playlist, url, nil
 }
+
+func (this *TrackResolver) buildDashPlaylist(ctx context.Context, manifest *dash.Manifest) (*hls.TrackPlaylist, *url.URL, error) {
+	playlist, err := manifest.TrackPlaylist(this.TrackType, this.TrackIndex, func(url *url.URL, offset int64, length int64) ([]byte, error) {
+		return this.FileDownloader.DownloadRange(ctx, url, offset, length)
+	}, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return playlist, manifest.URL, nil
+}
@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wails-cast/pkg/ffmpeg"
+	"wails-cast/pkg/filehelper"
+	"wails-cast/pkg/logger"
+)
+
+// VideoAnalysis is the interlacing and black bar analysis of a local file,
+// persisted in the media's cache folder next to the segment map. Sources
+// streamed from a URL have no size nor modification time; their cache folder
+// is specific to the URL.
+type VideoAnalysis struct {
+	SourceSize    int64
+	SourceModTime int64
+	VideoStream   int
+	ffmpeg.VideoAnalysis
+}
+
+// LoadVideoAnalysis returns the cached analysis of a file or URL, analyzing
+// it again when the cache is missing or the file changed. Failures are
+// logged and yield an empty analysis, which applies no filters.
+func LoadVideoAnalysis(videoPath string, storageDirectory string, videoStream int, duration float64, resolution string) *VideoAnalysis {
+	analysisPath := filepath.Join(storageDirectory, fmt.Sprintf("video_analysis_%d.json", videoStream))
+
+	var size, modTime int64
+	if stat, err := os.Stat(videoPath); err == nil {
+		size = stat.Size()
+		modTime = stat.ModTime().Unix()
+	}
+	cached, err := filehelper.ReadJson[VideoAnalysis](analysisPath)
+	if err == nil && cached.SourceSize == size && cached.SourceModTime == modTime {
+		return cached
+	}
+
+	var width, height int
+	fmt.Sscanf(resolution, "%dx%d", &width, &height)
+
+	analysis := &VideoAnalysis{VideoStream: videoStream}
+	result, err := ffmpeg.AnalyzeVideo(videoPath, videoStream, duration, width, height)
+	if err != nil {
+		logger.Logger.Warn("Failed to analyze video", "path", videoPath, "error", err)
+		return analysis
+	}
+	analysis.VideoAnalysis = *result
+	logger.Logger.Info("Analyzed video", "path", videoPath, "interlaced", result.Interlaced, "crop", result.Crop)
+
+	analysis.SourceSize = size
+	analysis.SourceModTime = modTime
+	filehelper.WriteJson(analysisPath, analysis)
+
+	return analysis
+}
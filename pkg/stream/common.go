@@ -3,8 +3,13 @@ package stream
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"wails-cast/pkg/ffmpeg"
+	"wails-cast/pkg/hls"
 	"wails-cast/pkg/mix"
+	"wails-cast/pkg/options"
 	"wails-cast/pkg/subtitles"
 )
 
@@ -17,6 +22,84 @@ func GetEmbeddedIndex(subtitlePath string) (int, bool) {
 	return index, true
 }
 
+// resolutionWidth returns the width of a "WxH" resolution, or 0 if unknown
+func resolutionWidth(resolution string) int {
+	var width, height int
+	if _, err := fmt.Sscanf(resolution, "%dx%d", &width, &height); err != nil {
+		return 0
+	}
+	return width
+}
+
+// channelCount returns the channel count of a CHANNELS attribute such as "6"
+// or "16/JOC", or 0 if unknown
+func channelCount(channels string) int {
+	count, _ := strconv.Atoi(strings.SplitN(channels, "/", 2)[0])
+	return count
+}
+
+// audioBandwidth approximates the transcoded AAC track in BANDWIDTH attributes
+const audioBandwidth = 128000
+
+// scaledResolution returns the "WxH" output resolution of a source scaled down
+// to maxWidth (0 = unscaled), or "" if the source resolution is unknown
+func scaledResolution(resolution string, maxWidth int) string {
+	var width, height int
+	if _, err := fmt.Sscanf(resolution, "%dx%d", &width, &height); err != nil || width == 0 {
+		return ""
+	}
+	if maxWidth > 0 && width > maxWidth {
+		// Matches the scale filter's even "-2" height
+		height = height * maxWidth / width / 2 * 2
+		width = maxWidth
+	}
+	return fmt.Sprintf("%dx%d", width, height)
+}
+
+// variantBandwidth returns the BANDWIDTH of a served variant: the encoder
+// bitrate plus audio when transcoding at a fixed bitrate, otherwise the
+// source's bandwidth
+func variantBandwidth(opts options.StreamOptions, source int) int {
+	bandwidth := opts.VideoBandwidth(source)
+	if bandwidth != source {
+		bandwidth += audioBandwidth
+	}
+	return bandwidth
+}
+
+// isStyledSubtitleFile reports whether an external subtitle file is ASS/SSA
+func isStyledSubtitleFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".ass" || ext == ".ssa"
+}
+
+// transcodedVideoCodec is the CODECS entry of re-encoded video
+const transcodedVideoCodec = ffmpeg.VideoCodecString
+
+// variantVideoRange returns the VIDEO-RANGE of a served variant: the
+// source's range when HDR is passed through, SDR once tone-mapped
+func variantVideoRange(opts options.StreamOptions) string {
+	if opts.ToneMapped() {
+		return "SDR"
+	}
+	if opts.Source.HDR() {
+		return hls.VideoRange(opts.Source.Transfer)
+	}
+	return ""
+}
+
+// toneMapOptions returns the ffmpeg tone mapping for HDR sources that are
+// transcoded, or nil
+func toneMapOptions(opts options.StreamOptions) *ffmpeg.ToneMapOptions {
+	if !opts.ToneMapped() {
+		return nil
+	}
+	return &ffmpeg.ToneMapOptions{
+		Transfer:  opts.Source.Transfer,
+		Primaries: opts.Source.Primaries,
+	}
+}
+
 func GetExternalPath(subtitlePath string) (string, bool) {
 	path, found := strings.CutPrefix(subtitlePath, "external:")
 	if found {
@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wails-cast/pkg/ffmpeg"
+	"wails-cast/pkg/filehelper"
+	"wails-cast/pkg/folders"
+	"wails-cast/pkg/mix"
+	"wails-cast/pkg/options"
+	"wails-cast/pkg/remote"
+)
+
+// Export formats
+const (
+	ExportMP4 = "mp4"
+	ExportMKV = "mkv"
+)
+
+// ExportOptions selects the tracks and subtitles written by ExportRemote
+type ExportOptions struct {
+	VideoTrack int
+	AudioTrack int
+	Subtitle   options.SubtitleCastOptions
+	// Folder receives the file, which is named after the extracted title
+	Folder string
+	Format string
+}
+
+// ExportProgress is reported while an export runs
+type ExportProgress struct {
+	URL      string
+	Progress float64
+}
+
+// ExportRemote muxes the downloaded tracks of a remote media into a single
+// MP4 or MKV file and returns its path. Every segment of the selected tracks
+// must be downloaded.
+func ExportRemote(ctx context.Context, media *remote.MediaManager, opts ExportOptions, progress func(float64)) (string, error) {
+	if opts.Format != ExportMP4 && opts.Format != ExportMKV {
+		return "", fmt.Errorf("unsupported export format %s", opts.Format)
+	}
+	if opts.Folder == "" {
+		return "", fmt.Errorf("no export folder selected")
+	}
+
+	videoManager, err := media.GetTrack(ctx, "video", opts.VideoTrack)
+	if err != nil {
+		return "", err
+	}
+	videoSegments, err := videoManager.SegmentFiles()
+	if err != nil {
+		return "", fmt.Errorf("video track %d is incomplete: %w", opts.VideoTrack, err)
+	}
+	var audioSegments []string
+	if len(media.Playlist().AudioTracks) > 0 {
+		audioManager, err := media.GetTrack(ctx, "audio", opts.AudioTrack)
+		if err != nil {
+			return "", err
+		}
+		audioSegments, err = audioManager.SegmentFiles()
+		if err != nil {
+			return "", fmt.Errorf("audio track %d is incomplete: %w", opts.AudioTrack, err)
+		}
+	}
+
+	mux := &ffmpeg.MuxOptions{
+		VideoSegments: videoSegments,
+		AudioSegments: audioSegments,
+		Duration:      media.GetDuration(),
+		Output:        filepath.Join(opts.Folder, filehelper.ConvertToUsableFilename(media.Title)+"."+opts.Format),
+		Title:         media.Title,
+	}
+	if err := exportSubtitle(mux, opts.Subtitle, folders.Video(media.URL)); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(opts.Folder, 0755); err != nil {
+		return "", err
+	}
+	// Earlier exports of the same media are kept
+	mux.Output = filehelper.UniquePath(mux.Output)
+	return mux.Output, ffmpeg.Mux(ctx, mux, progress)
+}
+
+// exportSubtitle adds the selected subtitles to an export, as a soft track
+// or burned into the video
+func exportSubtitle(mux *ffmpeg.MuxOptions, subtitle options.SubtitleCastOptions, storageDirectory string) error {
+	if subtitle.Path == "" || subtitle.Path == "none" {
+		return nil
+	}
+	var path string
+	if external, found := GetExternalPath(subtitle.Path); found {
+		path = external
+	} else if index, found := GetEmbeddedIndex(subtitle.Path); found {
+		path = filepath.Join(storageDirectory, fmt.Sprintf("subtitle_%d.vtt", index))
+	} else {
+		return fmt.Errorf("unsupported subtitle path format %s", subtitle.Path)
+	}
+
+	burnIn := &ffmpeg.SubtitleTranscodeOptions{
+		Source:     subtitle.Path,
+		FontSize:   subtitle.FontSize,
+		Bold:       subtitle.Bold,
+		Italic:     subtitle.Italic,
+		ForceStyle: subtitle.ForceStyle,
+		Delay:      subtitle.DelaySeconds,
+	}
+	if isStyledSubtitleFile(path) {
+		burnIn.Path = path
+		burnIn.Styled = true
+	} else {
+		// Timing, closed captions and, for soft subtitles, styling are
+		// applied to a copy, as when casting
+		bold := subtitle.Bold && !subtitle.BurnIn
+		italic := subtitle.Italic && !subtitle.BurnIn
+		processed, err := ProcessSubtitles(mix.File(path), mix.FileTarget(filepath.Join(storageDirectory, "export_subtitles.vtt")), subtitle.IgnoreClosedCaptions, subtitle.DelaySeconds, bold, italic)
+		if err != nil {
+			return fmt.Errorf("failed to process subtitles: %w", err)
+		}
+		path = processed.FilePath
+		burnIn.Path = path
+	}
+
+	if subtitle.BurnIn {
+		mux.BurnIn = burnIn
+	} else {
+		mux.Subtitle = path
+		if burnIn.Styled {
+			mux.SubtitleDelay = burnIn.Delay
+		}
+	}
+	return nil
+}
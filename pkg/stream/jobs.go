@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"wails-cast/pkg/ffmpeg"
+	"wails-cast/pkg/mix"
+)
+
+// segmentJobs deduplicates transcodes of the same segment across handlers,
+// keyed by the segment's cache path
+var segmentJobs = &jobRegistry{jobs: make(map[string]*segmentJob)}
+
+// jobRegistry runs at most one job per key; concurrent callers wait for the
+// running job and share its result
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*segmentJob
+}
+
+type segmentJob struct {
+	// priority is raised when playback joins a job started by a prefetch
+	priority *ffmpeg.Priority
+	done     chan struct{}
+	result   *mix.FileOrBuffer
+	err      error
+	waiters  int
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// Do runs fn for key unless a job for key is already running, in which case
+// it waits for that job. The job keeps running while any caller still waits
+// and is cancelled once all of them have gone.
+func (r *jobRegistry) Do(ctx context.Context, key string, fn func(ctx context.Context) (*mix.FileOrBuffer, error)) (*mix.FileOrBuffer, error) {
+	for {
+		r.mu.Lock()
+		job, found := r.jobs[key]
+		if found && job.canceled {
+			// Let an abandoned job clean up its output before starting over
+			r.mu.Unlock()
+			select {
+			case <-job.done:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if !found {
+			job = r.start(ctx, key, fn)
+		}
+		job.priority.Join(ctx)
+		job.waiters++
+		r.mu.Unlock()
+
+		select {
+		case <-job.done:
+			return job.result, job.err
+		case <-ctx.Done():
+			r.mu.Lock()
+			job.waiters--
+			if job.waiters == 0 {
+				job.canceled = true
+				job.cancel()
+			}
+			r.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// start launches a job detached from the caller's cancellation; r.mu must be held
+func (r *jobRegistry) start(ctx context.Context, key string, fn func(ctx context.Context) (*mix.FileOrBuffer, error)) *segmentJob {
+	// The job outlives the caller, and its priority follows all its waiters
+	priority := ffmpeg.NewPriority(ctx)
+	jobCtx, cancel := context.WithCancel(ffmpeg.WithPriority(context.WithoutCancel(ctx), priority))
+	job := &segmentJob{
+		priority: priority,
+		done:     make(chan struct{}),
+		cancel:   cancel,
+	}
+	r.jobs[key] = job
+
+	go func() {
+		job.result, job.err = fn(jobCtx)
+		cancel()
+		r.mu.Lock()
+		delete(r.jobs, key)
+		r.mu.Unlock()
+		close(job.done)
+	}()
+	return job
+}
@@ -5,14 +5,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"wails-cast/pkg/ffmpeg"
 	"wails-cast/pkg/filehelper"
 	"wails-cast/pkg/folders"
 	"wails-cast/pkg/hls"
+	"wails-cast/pkg/logger"
 	"wails-cast/pkg/mix"
 	"wails-cast/pkg/options"
+	"wails-cast/pkg/remote"
 	"wails-cast/pkg/urlhelper"
 )
 
@@ -22,38 +25,203 @@ type LocalHandler struct {
 	Options          options.StreamOptions
 	Duration         float64
 	SegmentSize      int
+	SegmentMap       *SegmentMap
 	StorageDirectory string
+	// SegmentDirectory holds the transcoded segments; each ABR rendition
+	// caches its segments in a subfolder of StorageDirectory
+	SegmentDirectory string
+	// URLPrefix is prepended to segment URLs, e.g. "/rendition_1"
+	URLPrefix   string
+	SourceVideo hls.VideoTrack
+	// SourceAudio is empty for files without audio
+	SourceAudio hls.AudioTrack
+	// SourceSubtitles are the embedded subtitle tracks
+	SourceSubtitles []hls.SubtitleTrack
+	renditions      []*LocalHandler
 }
 
 // NewLocalHandler creates a new local HLS handler
 func NewLocalHandler(videoPath string, options options.StreamOptions) *LocalHandler {
+	return newLocalHandler(videoPath, folders.Video(videoPath), options, true)
+}
+
+// NewProgressiveHandler creates a handler for a remote media file, read
+// through the loopback proxy of its source. Probing its keyframes would
+// download the whole file, so it is cut on a fixed grid and its video is
+// always re-encoded.
+func NewProgressiveHandler(source *remote.ProgressiveSource, options options.StreamOptions) *LocalHandler {
+	return newLocalHandler(source.LocalURL(), folders.Video(source.URL.String()), options, false)
+}
+
+func newLocalHandler(videoPath string, storageDirectory string, options options.StreamOptions, seekable bool) *LocalHandler {
 	duration, err := ffmpeg.GetVideoDuration(videoPath)
 	if err != nil {
 		duration = 0
 	}
 
-	return &LocalHandler{
+	sourceVideo, sourceAudio, sourceSubtitles := probeLocalSource(videoPath, &options)
+
+	segmentSize := 8
+	segmentMap := &SegmentMap{
+		VideoStream:    options.VideoTrack,
+		TargetDuration: float64(segmentSize),
+		Segments:       PlanSegments(nil, duration, float64(segmentSize)),
+	}
+	if seekable {
+		segmentMap = LoadSegmentMap(videoPath, storageDirectory, options.VideoTrack, duration, float64(segmentSize))
+	} else {
+		// Copied segments must start on keyframes
+		options.CopyVideo = false
+	}
+
+	if options.Picture.Analyzed() {
+		analysis := LoadVideoAnalysis(videoPath, storageDirectory, options.VideoTrack, duration, sourceVideo.Resolution)
+		options.Picture.Interlaced = analysis.Interlaced
+		options.Picture.Crop = analysis.Crop
+	}
+
+	handler := &LocalHandler{
 		VideoPath:        videoPath,
 		Options:          options,
 		Duration:         duration,
-		SegmentSize:      8,
-		StorageDirectory: folders.Video(videoPath),
+		SegmentSize:      segmentSize,
+		SegmentMap:       segmentMap,
+		StorageDirectory: storageDirectory,
+		SegmentDirectory: storageDirectory,
+		SourceVideo:      sourceVideo,
+		SourceAudio:      sourceAudio,
+		SourceSubtitles:  sourceSubtitles,
 	}
+
+	handler.Options.Subtitle.Bitmap = handler.isBitmapSubtitle(options.Subtitle.Path)
+
+	for i, rendition := range options.LadderBelow(sourceVideo.Bandwidth) {
+		handler.renditions = append(handler.renditions, handler.newRendition(i+1, rendition))
+	}
+
+	return handler
+}
+
+// newRendition derives the handler of an ABR rendition. It shares the source
+// and segment map and caches its segments in its own folder.
+func (s *LocalHandler) newRendition(index int, rendition options.Rendition) *LocalHandler {
+	name := fmt.Sprintf("rendition_%d", index)
+	segmentDirectory := filepath.Join(s.StorageDirectory, name)
+	os.MkdirAll(segmentDirectory, 0755)
+
+	return &LocalHandler{
+		VideoPath:        s.VideoPath,
+		Options:          s.Options.ForRendition(rendition),
+		Duration:         s.Duration,
+		SegmentSize:      s.SegmentSize,
+		SegmentMap:       s.SegmentMap,
+		StorageDirectory: s.StorageDirectory,
+		SegmentDirectory: segmentDirectory,
+		URLPrefix:        "/" + name,
+		SourceVideo:      s.SourceVideo,
+		SourceAudio:      s.SourceAudio,
+		SourceSubtitles:  s.SourceSubtitles,
+	}
+}
+
+// probeLocalSource probes the selected tracks so segments of files the
+// receiver can already decode are stream-copied instead of re-encoded. The
+// probed tracks describe the variants of the master playlist.
+func probeLocalSource(videoPath string, opts *options.StreamOptions) (hls.VideoTrack, hls.AudioTrack, []hls.SubtitleTrack) {
+	info, err := ffmpeg.GetMediaTrackInfo(videoPath)
+	if err != nil || len(info.VideoTracks) == 0 {
+		return hls.VideoTrack{}, hls.AudioTrack{}, nil
+	}
+
+	video := info.VideoTracks[0]
+	if opts.VideoTrack >= 0 && opts.VideoTrack < len(info.VideoTracks) {
+		video = info.VideoTracks[opts.VideoTrack]
+	}
+	level := video.Level
+	// ffprobe reports the HEVC general_level_idc, which is 30 * level
+	if video.Codecs == "hevc" {
+		level = level * 10 / 30
+	}
+	codecs := hls.CodecInfo{
+		Video:    video.Codecs,
+		Profile:  video.Profile,
+		Level:    level,
+		BitDepth: video.BitDepth,
+
+		Transfer:  video.ColorTransfer,
+		Primaries: video.ColorPrimaries,
+	}
+	var audio hls.AudioTrack
+	if opts.AudioTrack >= 0 && opts.AudioTrack < len(info.AudioTracks) {
+		audio = info.AudioTracks[opts.AudioTrack]
+	} else if len(info.AudioTracks) > 0 {
+		audio = info.AudioTracks[0]
+	}
+	codecs.Audio = audio.Codec
+
+	opts.ResolveCopyMode(codecs, resolutionWidth(video.Resolution), video.Bandwidth)
+	return video, audio, info.SubtitleTracks
 }
 
 // ServeManifestPlaylist generates the manifest HLS playlist
 func (s *LocalHandler) ServeManifestPlaylist(ctx context.Context) (string, error) {
+	// Stream-copied video keeps the codec, profile and level of the source
+	transcoded := transcodedVideoCodec + ",mp4a.40.2"
+	codecs := transcoded
+	if s.Options.VideoCopied() {
+		if video := hls.VideoCodecString(s.Options.Source); video != "" {
+			codecs = video + ",mp4a.40.2"
+		}
+	}
+	var audioGroup string
+	var audioTracks []hls.AudioTrack
+
+	// The audio is muxed into the video segments; an EXT-X-MEDIA entry
+	// without URI advertises its channel layout
+	if s.SourceAudio.Codec != "" {
+		audioCodec, channels := s.Options.AudioOutput(s.SourceAudio.Codec, channelCount(s.SourceAudio.Channels))
+		codecs = hls.ReplaceAudioCodec(codecs, hls.AudioCodecString(audioCodec))
+		transcoded = hls.ReplaceAudioCodec(transcoded, hls.AudioCodecString(audioCodec))
+		if channels > 0 {
+			audioGroup = "audio"
+			audioTracks = append(audioTracks, hls.AudioTrack{
+				GroupID:    audioGroup,
+				Name:       "Audio",
+				Default:    true,
+				Autoselect: true,
+				Channels:   strconv.Itoa(channels),
+			})
+		}
+	}
+
 	manifestPlaylist := &hls.ManifestPlaylist{
-		Version: 3,
+		Version:     3,
+		AudioTracks: audioTracks,
 		VideoTracks: []hls.VideoTrack{
 			{
-				Index:  0,
-				Codecs: "avc1.4d401f,mp4a.40.2",
-				URI:    urlhelper.ParseFixed("/video.m3u8"),
+				Index:      0,
+				Bandwidth:  variantBandwidth(s.Options, s.SourceVideo.Bandwidth),
+				Codecs:     codecs,
+				Resolution: scaledResolution(s.SourceVideo.Resolution, s.Options.MaxOutputWidth),
+				Audio:      audioGroup,
+				VideoRange: variantVideoRange(s.Options),
+				URI:        urlhelper.ParseFixed("/video.m3u8"),
 			},
 		},
 	}
 
+	for _, rendition := range s.renditions {
+		manifestPlaylist.VideoTracks = append(manifestPlaylist.VideoTracks, hls.VideoTrack{
+			Index:      len(manifestPlaylist.VideoTracks),
+			Bandwidth:  variantBandwidth(rendition.Options, 0),
+			Codecs:     transcoded,
+			Resolution: scaledResolution(s.SourceVideo.Resolution, rendition.Options.MaxOutputWidth),
+			Audio:      audioGroup,
+			VideoRange: variantVideoRange(rendition.Options),
+			URI:        urlhelper.UPrintf("%s/video.m3u8", rendition.URLPrefix),
+		})
+	}
+
 	return manifestPlaylist.Generate(), nil
 }
 
@@ -61,64 +229,67 @@ func (s *LocalHandler) ServeManifestPlaylist(ctx context.Context) (string, error
 func (s *LocalHandler) ServeTrackPlaylist(ctx context.Context, trackType string) (string, error) {
 	trackPlaylist := &hls.TrackPlaylist{
 		Version:        3,
-		TargetDuration: s.SegmentSize,
+		TargetDuration: s.SegmentMap.MaxDuration(),
 		MediaSequence:  0,
 		Segments:       make([]*hls.Segment, 0),
 		EndList:        true,
 	}
 
-	numSegments := int(s.Duration) / s.SegmentSize
-	if int(s.Duration)%s.SegmentSize != 0 {
-		numSegments++
+	if s.Options.FragmentedMP4() {
+		// EXT-X-MAP in a non I-frame playlist requires version 6
+		trackPlaylist.Version = 6
+		trackPlaylist.Map = &hls.Map{URI: fmt.Sprintf("%s/%s/init.mp4", s.URLPrefix, trackType)}
 	}
 
 	// Add program date time tags for better sync
 	baseTime := time.Now()
-	cumulativeTime := 0.0
-
-	for i := 0; i < numSegments; i++ {
-		segmentDuration := float64(s.SegmentSize)
-		if float64((i+1)*s.SegmentSize) > s.Duration {
-			segmentDuration = s.Duration - float64(i*s.SegmentSize)
-		}
 
+	for i, segmentRange := range s.SegmentMap.Segments {
 		// Calculate program date time for this segment
-		segmentTime := baseTime.Add(time.Duration(cumulativeTime * float64(time.Second)))
+		segmentTime := baseTime.Add(time.Duration(segmentRange.Start * float64(time.Second)))
 
 		segment := &hls.Segment{
-			Duration:        segmentDuration,
+			Duration:        segmentRange.Duration,
 			Title:           "",
-			URI:             urlhelper.UPrintf("/%s/segment_%d.ts", trackType, i),
+			URI:             urlhelper.UPrintf("%s/%s/segment_%d.%s", s.URLPrefix, trackType, i, s.Options.SegmentExtension()),
 			ProgramDateTime: segmentTime.Format(time.RFC3339Nano),
 		}
 		trackPlaylist.Segments = append(trackPlaylist.Segments, segment)
-		cumulativeTime += segmentDuration
 	}
 	return trackPlaylist.Generate(), nil
 }
 
 // ServeSegment transcodes and returns the segment file path
 func (s *LocalHandler) ServeSegment(ctx context.Context, trackType string, segmentIndex int) (*mix.FileOrBuffer, error) {
-	segmentName := fmt.Sprintf("segment_%d.ts", segmentIndex)
-	segmentPath := s.cacheFile(segmentName)
-	segmentDuration := float64(s.SegmentSize)
-	startTime := float64(segmentIndex * s.SegmentSize)
-	if startTime+segmentDuration > s.Duration {
-		segmentDuration = s.Duration - startTime
+	if segmentIndex < 0 || segmentIndex >= len(s.SegmentMap.Segments) {
+		return nil, fmt.Errorf("segment %d out of range", segmentIndex)
 	}
+	segment := s.SegmentMap.Segments[segmentIndex]
+	segmentName := fmt.Sprintf("segment_%d.%s", segmentIndex, s.Options.SegmentExtension())
+	segmentPath := s.cacheFile(segmentName)
 
+	return segmentJobs.Do(ctx, segmentPath, func(ctx context.Context) (*mix.FileOrBuffer, error) {
+		return s.serveSegment(ctx, segment, segmentPath)
+	})
+}
+
+// serveSegment returns the cached segment, transcoding it when the cache is
+// missing or was made with different options
+func (s *LocalHandler) serveSegment(ctx context.Context, segment SegmentRange, segmentPath string) (*mix.FileOrBuffer, error) {
 	if s.Options.NoTranscodeCache {
-		return s.transcodeSegment(ctx, mix.BufferTarget(), startTime)
+		return s.transcodeSegment(ctx, mix.BufferTarget(), segment)
 	}
 
 	manifest, err := ffmpeg.LoadSegmentManifest(segmentPath + ".json")
 
+	// Segments cached before a re-plan may cover a different time range
 	needsRegeneration := err != nil ||
-		!ffmpeg.ManifestMatches(manifest, s.Options, s.SegmentSize) ||
+		manifest.StartTime != segment.Start ||
+		!ffmpeg.ManifestMatches(manifest, s.Options, segment.Duration, s.streams(), s.styledSubtitle()) ||
 		!filehelper.Exists(segmentPath)
 
 	if needsRegeneration {
-		buffer, err := s.transcodeSegment(ctx, mix.FileTarget(segmentPath), startTime)
+		buffer, err := s.transcodeSegment(ctx, mix.FileTarget(segmentPath), segment)
 		if err != nil {
 			return nil, fmt.Errorf("transcode failed: %w", err)
 		}
@@ -128,35 +299,56 @@ func (s *LocalHandler) ServeSegment(ctx context.Context, trackType string, segme
 	return mix.File(segmentPath), nil
 }
 
-func (s *LocalHandler) transcodeSegment(ctx context.Context, target *mix.TargetFileOrBuffer, startTime float64) (*mix.FileOrBuffer, error) {
-	linkPath := filepath.Join(s.StorageDirectory, "input_video")
-	err := filehelper.EnsureSymlink(s.VideoPath, linkPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create symlink: %w", err)
+// ServeInitSegment returns the fMP4 init segment, which is written alongside
+// every transcoded segment. Serving the first segment makes sure it matches
+// the current options.
+func (s *LocalHandler) ServeInitSegment(ctx context.Context, trackType string) (*mix.FileOrBuffer, error) {
+	if !s.Options.FragmentedMP4() {
+		return nil, fmt.Errorf("init segments are only served for fMP4 output")
+	}
+	if _, err := s.ServeSegment(ctx, trackType, 0); err != nil {
+		return nil, err
+	}
+	return mix.File(s.cacheFile("init.mp4")), nil
+}
+
+func (s *LocalHandler) transcodeSegment(ctx context.Context, target *mix.TargetFileOrBuffer, segment SegmentRange) (*mix.FileOrBuffer, error) {
+	// Files are read through a symlink; progressive sources by URL
+	linkPath := s.VideoPath
+	var err error
+	if !urlhelper.IsURL(s.VideoPath) {
+		linkPath = filepath.Join(s.StorageDirectory, "input_video")
+		err = filehelper.EnsureSymlink(s.VideoPath, linkPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create symlink: %w", err)
+		}
 	}
 
 	var subtitle *ffmpeg.SubtitleTranscodeOptions = nil
 
-	if s.Options.Subtitle.BurnIn {
-		path := filepath.Join(s.StorageDirectory, "subtitles.vtt")
-		_, err := s.getSubtitles(mix.FileTarget(path))
+	if s.Options.Subtitle.BurnsIn() {
+		subtitle, err = s.burnInSubtitle()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get subtitles for burn-in: %w", err)
 		}
-		subtitle = &ffmpeg.SubtitleTranscodeOptions{
-			Path:     path,
-			FontSize: s.Options.Subtitle.FontSize,
-			Bold:     s.Options.Subtitle.Bold,
-			Italic:   s.Options.Subtitle.Italic,
-		}
 	}
 
 	opts := &ffmpeg.TranscodeOptions{
-		StartTime:      startTime,
-		Duration:       s.SegmentSize,
+		StartTime:      segment.Start,
+		Duration:       segment.Duration,
 		Subtitle:       subtitle,
 		MaxOutputWidth: s.Options.MaxOutputWidth,
 		Bitrate:        s.Options.Bitrate,
+		CopyVideo:      s.Options.VideoCopied(),
+		CopyAudio:      s.Options.AudioCopied(),
+		Audio:          s.Options.Audio,
+		Picture:        s.Options.Picture,
+		ToneMap:        toneMapOptions(s.Options),
+		Streams:        s.streams(),
+	}
+	if s.Options.FragmentedMP4() {
+		opts.Container = options.ContainerFMP4
+		opts.InitSegmentPath = s.cacheFile("init.mp4")
 	}
 
 	output, err := ffmpeg.TranscodeSegment(ctx, mix.File(linkPath), target, opts)
@@ -164,23 +356,147 @@ func (s *LocalHandler) transcodeSegment(ctx context.Context, target *mix.TargetF
 	if err != nil {
 		return nil, err
 	}
+	if !output.IsBuffer {
+		err = opts.Save(output.FilePath + ".json")
+	}
 
 	return output, err
 }
 
+// streams maps the selected video and audio tracks onto the input's streams
+func (s *LocalHandler) streams() *ffmpeg.StreamSelection {
+	return &ffmpeg.StreamSelection{
+		Video: s.Options.VideoTrack,
+		Audio: s.Options.AudioTrack,
+	}
+}
+
 func (s *LocalHandler) cacheFile(segmentName string) string {
-	return filepath.Join(folders.Video(s.VideoPath), segmentName)
+	return filepath.Join(s.SegmentDirectory, segmentName)
+}
+
+// Rendition returns the handler of an ABR rendition, numbered from 1
+func (s *LocalHandler) Rendition(index int) (StreamHandler, error) {
+	if index < 1 || index > len(s.renditions) {
+		return nil, fmt.Errorf("rendition %d not found", index)
+	}
+	return s.renditions[index-1], nil
+}
+
+// burnInSubtitle prepares the subtitle rendered into the segments. Bitmap
+// tracks are overlaid from the file itself, ASS/SSA tracks are kept as is
+// with the fonts attached to the file, and everything else is converted to
+// VTT.
+func (s *LocalHandler) burnInSubtitle() (*ffmpeg.SubtitleTranscodeOptions, error) {
+	subtitle := &ffmpeg.SubtitleTranscodeOptions{
+		Source:     s.Options.Subtitle.Path,
+		FontSize:   s.Options.Subtitle.FontSize,
+		Bold:       s.Options.Subtitle.Bold,
+		Italic:     s.Options.Subtitle.Italic,
+		ForceStyle: s.Options.Subtitle.ForceStyle,
+		Delay:      s.Options.Subtitle.DelaySeconds,
+	}
+
+	if s.Options.Subtitle.Bitmap {
+		index, _ := GetEmbeddedIndex(s.Options.Subtitle.Path)
+		subtitle.Path = s.Options.Subtitle.Path
+		subtitle.Bitmap = true
+		subtitle.StreamIndex = index
+		return subtitle, nil
+	}
+
+	if path, found := GetExternalPath(s.Options.Subtitle.Path); found && isStyledSubtitleFile(path) {
+		subtitle.Path = path
+		subtitle.Styled = true
+		return subtitle, nil
+	}
+
+	if s.styledSubtitle() {
+		index, _ := GetEmbeddedIndex(s.Options.Subtitle.Path)
+		path := filepath.Join(s.StorageDirectory, fmt.Sprintf("subtitles_%d.ass", index))
+		if !filehelper.Exists(path) {
+			// Extract next to the final path so concurrent segments never
+			// read a partial file
+			tmpPath := path + ".tmp"
+			if _, err := ffmpeg.ExtractStyledSubtitle(s.VideoPath, index, mix.FileTarget(tmpPath)); err != nil {
+				return nil, err
+			}
+			if err := os.Rename(tmpPath, path); err != nil {
+				return nil, err
+			}
+		}
+		subtitle.Path = path
+		subtitle.Styled = true
+		subtitle.FontsDir = s.fontsDir()
+		return subtitle, nil
+	}
+
+	path := filepath.Join(s.StorageDirectory, "subtitles.vtt")
+	if _, err := s.getSubtitles(mix.FileTarget(path)); err != nil {
+		return nil, err
+	}
+	subtitle.Path = path
+	return subtitle, nil
+}
+
+// styledSubtitle reports whether the burned in subtitle is an ASS/SSA file
+// or track, rendered with its own styles
+func (s *LocalHandler) styledSubtitle() bool {
+	if s.Options.Subtitle.Bitmap {
+		return false
+	}
+	if path, found := GetExternalPath(s.Options.Subtitle.Path); found {
+		return isStyledSubtitleFile(path)
+	}
+	index, found := GetEmbeddedIndex(s.Options.Subtitle.Path)
+	return found && index < len(s.SourceSubtitles) && ffmpeg.IsStyledSubtitle(s.SourceSubtitles[index].Codec)
+}
+
+// fontsDir returns the folder with the fonts attached to the file, dumping
+// them on first use
+func (s *LocalHandler) fontsDir() string {
+	fontsDir := filepath.Join(s.StorageDirectory, "fonts")
+	if filehelper.Exists(fontsDir) {
+		return fontsDir
+	}
+
+	tmpDir, err := os.MkdirTemp(s.StorageDirectory, "fonts_")
+	if err != nil {
+		return ""
+	}
+	count, err := ffmpeg.ExtractFontAttachments(s.VideoPath, tmpDir)
+	if err != nil {
+		logger.Logger.Warn("Failed to extract font attachments", "path", s.VideoPath, "error", err)
+	} else {
+		logger.Logger.Info("Extracted font attachments", "path", s.VideoPath, "count", count)
+	}
+	// Another segment may have won the race; its folder is just as good
+	if err := os.Rename(tmpDir, fontsDir); err != nil {
+		os.RemoveAll(tmpDir)
+	}
+	return fontsDir
 }
 
 // UpdateSubtitleOptions replaces the live subtitle options (path, font size,
 // style, timing offset) so subsequent subtitle/segment serving uses them.
 func (this *LocalHandler) UpdateSubtitleOptions(opts options.SubtitleCastOptions) {
+	opts.Bitmap = this.isBitmapSubtitle(opts.Path)
 	this.Options.Subtitle = opts
+	for _, rendition := range this.renditions {
+		rendition.UpdateSubtitleOptions(opts)
+	}
+}
+
+// isBitmapSubtitle reports whether a subtitle path is an embedded image-based
+// track
+func (this *LocalHandler) isBitmapSubtitle(subtitlePath string) bool {
+	index, found := GetEmbeddedIndex(subtitlePath)
+	return found && index < len(this.SourceSubtitles) && ffmpeg.IsBitmapSubtitle(this.SourceSubtitles[index].Codec)
 }
 
 // ServeSubtitles returns the subtitle file in WebVTT format
 func (this *LocalHandler) ServeSubtitles(ctx context.Context) (*mix.FileOrBuffer, error) {
-	if this.Options.Subtitle.Path == "none" || this.Options.Subtitle.BurnIn {
+	if this.Options.Subtitle.Path == "none" || this.Options.Subtitle.BurnsIn() {
 		return nil, fmt.Errorf("no external subtitles available")
 	}
 
@@ -0,0 +1,124 @@
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"wails-cast/pkg/ffmpeg"
+	"wails-cast/pkg/logger"
+)
+
+// Prefetcher transcodes the segments following the playhead in the background
+// so they are already in the segment cache when the receiver requests them
+type Prefetcher struct {
+	ahead  int
+	jobs   chan prefetchJob
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	tracks map[string]*prefetchTrack
+}
+
+// prefetchTrack is the look-ahead window of one track type. Its context is
+// cancelled when the playhead jumps, dropping the queued and running work.
+type prefetchTrack struct {
+	handler StreamHandler
+	queued  int // highest segment index queued
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+type prefetchJob struct {
+	ctx          context.Context
+	handler      StreamHandler
+	trackType    string
+	segmentIndex int
+}
+
+// NewPrefetcher starts a pool of workers that keep the next ahead segments
+// transcoded. It returns nil (prefetching disabled) when ahead or workers is 0.
+func NewPrefetcher(ahead int, workers int) *Prefetcher {
+	if ahead <= 0 || workers <= 0 {
+		return nil
+	}
+
+	// Transcodes playback waits for take the free ffmpeg slots first
+	ctx, cancel := context.WithCancel(ffmpeg.WithPrefetch(context.Background()))
+	p := &Prefetcher{
+		ahead: ahead,
+		// Room for the video and audio windows
+		jobs:   make(chan prefetchJob, 2*ahead),
+		ctx:    ctx,
+		cancel: cancel,
+		tracks: make(map[string]*prefetchTrack),
+	}
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+// Advance moves the playhead of a track to a requested segment and queues the
+// segments after it. A request outside the current window (a seek) or from a
+// different handler (an ABR rendition switch) cancels the queued work first.
+func (p *Prefetcher) Advance(handler StreamHandler, trackType string, segmentIndex int) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	track := p.tracks[trackType]
+	if track == nil || track.handler != handler ||
+		segmentIndex < track.queued-p.ahead || segmentIndex > track.queued+1 {
+		if track != nil {
+			track.cancel()
+		}
+		ctx, cancel := context.WithCancel(p.ctx)
+		track = &prefetchTrack{
+			handler: handler,
+			queued:  segmentIndex,
+			ctx:     ctx,
+			cancel:  cancel,
+		}
+		p.tracks[trackType] = track
+	}
+
+	for i := max(track.queued, segmentIndex) + 1; i <= segmentIndex+p.ahead; i++ {
+		select {
+		case p.jobs <- prefetchJob{ctx: track.ctx, handler: handler, trackType: trackType, segmentIndex: i}:
+			track.queued = i
+		default:
+			// Workers are saturated; the next request queues the rest
+			return
+		}
+	}
+}
+
+// Stop cancels all prefetching and stops the workers
+func (p *Prefetcher) Stop() {
+	if p == nil {
+		return
+	}
+	p.cancel()
+}
+
+func (p *Prefetcher) work() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case job := <-p.jobs:
+			if job.ctx.Err() != nil {
+				continue
+			}
+			// ServeSegment returns cached segments untouched and caches new ones
+			_, err := job.handler.ServeSegment(job.ctx, job.trackType, job.segmentIndex)
+			if err != nil && job.ctx.Err() == nil {
+				logger.Logger.Debug("Prefetch failed", "type", job.trackType, "segment", job.segmentIndex, "error", err)
+			}
+		}
+	}
+}
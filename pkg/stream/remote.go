@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"wails-cast/pkg/ffmpeg"
@@ -29,6 +31,12 @@ type RemoteHandler struct {
 	VideoManager     *remote.TrackManager
 	AudioManager     *remote.TrackManager
 	StorageDirectory string
+	// SegmentDirectory holds the transcoded track folders; each ABR
+	// rendition uses a subfolder of StorageDirectory
+	SegmentDirectory string
+	// URLPrefix is prepended to segment URLs, e.g. "/rendition_1"
+	URLPrefix  string
+	renditions []*RemoteHandler
 }
 
 // NewRemoteHandler creates a new HLS handler
@@ -42,20 +50,59 @@ func NewRemoteHandler(
 	if err != nil {
 		return nil, err
 	}
+	manifest := mediaManager.Playlist()
 	var audioManager *remote.TrackManager
-	if len(mediaManager.Manifest.AudioTracks) > 0 {
+	if len(manifest.AudioTracks) > 0 {
 		audioManager, err = mediaManager.GetTrack(ctx, "audio", options.AudioTrack)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return &RemoteHandler{
+
+	// The variant's CODECS attribute covers both video and audio; sources
+	// without one are always transcoded. VIDEO-RANGE tells HDR apart.
+	variant := manifest.VideoTracks[options.VideoTrack]
+	codecs := hls.ParseCodecs(variant.Codecs)
+	codecs.Transfer = hls.VideoRangeTransfer(variant.VideoRange)
+	if codecs.HDR() {
+		codecs.Primaries = "bt2020"
+	}
+	options.ResolveCopyMode(codecs, resolutionWidth(variant.Resolution), variant.Bandwidth)
+	handler := &RemoteHandler{
 		Options:          options,
-		Manifest:         mediaManager.Manifest,
+		Manifest:         manifest,
 		VideoManager:     videoManager,
 		AudioManager:     audioManager,
 		StorageDirectory: folders.Video(mediaManager.URL),
-	}, nil
+		SegmentDirectory: folders.Video(mediaManager.URL),
+	}
+
+	for i, rendition := range options.LadderBelow(variant.Bandwidth) {
+		handler.renditions = append(handler.renditions, handler.newRendition(i+1, rendition))
+	}
+
+	// Live segment indexes restart whenever the track is loaded again, so
+	// segments transcoded for an earlier cast cannot be reused
+	if videoManager.Live() {
+		handler.clearTranscodedSegments()
+	}
+
+	return handler, nil
+}
+
+// newRendition derives the handler of an ABR rendition. It transcodes the
+// same source tracks into its own folder.
+func (this *RemoteHandler) newRendition(index int, rendition options.Rendition) *RemoteHandler {
+	name := fmt.Sprintf("rendition_%d", index)
+	return &RemoteHandler{
+		Options:          this.Options.ForRendition(rendition),
+		Manifest:         this.Manifest,
+		VideoManager:     this.VideoManager,
+		AudioManager:     this.AudioManager,
+		StorageDirectory: this.StorageDirectory,
+		SegmentDirectory: filepath.Join(this.StorageDirectory, name),
+		URLPrefix:        "/" + name,
+	}
 }
 
 // ServeManifestPlaylist generates the manifest playlist
@@ -63,17 +110,63 @@ func (this *RemoteHandler) ServeManifestPlaylist(ctx context.Context) (string, e
 	playlist := &hls.ManifestPlaylist{}
 
 	videoVariant := this.Manifest.VideoTracks[this.Options.VideoTrack]
+	sourceResolution := videoVariant.Resolution
+	sourceBandwidth := videoVariant.Bandwidth
 	videoVariant.Resolution = ""
 	videoVariant.URI = urlhelper.ParseFixed("/video.m3u8")
 	videoVariant.Subtitles = ""
+	if videoRange := variantVideoRange(this.Options); videoRange != "" {
+		videoVariant.VideoRange = videoRange
+	}
+
+	// Advertise the codec and channels of the audio as it is served
+	sourceAudio := hls.ParseCodecs(videoVariant.Codecs).Audio
+	sourceChannels := 0
+	if len(this.Manifest.AudioTracks) > 0 {
+		sourceChannels = channelCount(this.Manifest.AudioTracks[this.Options.AudioTrack].Channels)
+	}
+	audioCodec, channels := this.Options.AudioOutput(sourceAudio, sourceChannels)
+	if audioCodecString := hls.AudioCodecString(audioCodec); audioCodecString != "" && videoVariant.Codecs != "" {
+		videoVariant.Codecs = hls.ReplaceAudioCodec(videoVariant.Codecs, audioCodecString)
+	}
+	// Re-encoded video is H.264 whatever the source's codec
+	transcodedCodecs := videoVariant.Codecs
+	if transcodedCodecs != "" {
+		transcodedCodecs = hls.ReplaceVideoCodec(transcodedCodecs, transcodedVideoCodec)
+	}
+	if !this.Options.VideoCopied() {
+		videoVariant.Codecs = transcodedCodecs
+	}
 
 	if len(this.Manifest.AudioTracks) > 0 {
 		audio := this.Manifest.AudioTracks[this.Options.AudioTrack]
 		audio.URI = urlhelper.ParseFixed("/audio.m3u8")
+		if channels > 0 {
+			audio.Channels = strconv.Itoa(channels)
+		}
 		playlist.AudioTracks = []hls.AudioTrack{audio}
 	}
 
 	playlist.VideoTracks = []hls.VideoTrack{videoVariant}
+
+	// ABR variants need resolutions and bandwidths the receiver can pick from
+	if len(this.renditions) > 0 {
+		playlist.VideoTracks[0].Resolution = scaledResolution(sourceResolution, this.Options.MaxOutputWidth)
+		playlist.VideoTracks[0].Bandwidth = variantBandwidth(this.Options, sourceBandwidth)
+	}
+	for _, rendition := range this.renditions {
+		variant := videoVariant
+		variant.Index = len(playlist.VideoTracks)
+		variant.Bandwidth = variantBandwidth(rendition.Options, 0)
+		variant.Resolution = scaledResolution(sourceResolution, rendition.Options.MaxOutputWidth)
+		// Renditions are always re-encoded, so HDR sources are tone-mapped
+		// and the source's VIDEO-RANGE does not apply
+		variant.Codecs = transcodedCodecs
+		variant.VideoRange = variantVideoRange(rendition.Options)
+		variant.URI = urlhelper.UPrintf("%s/video.m3u8", rendition.URLPrefix)
+		playlist.VideoTracks = append(playlist.VideoTracks, variant)
+	}
+
 	return playlist.Generate(), nil
 }
 
@@ -87,19 +180,39 @@ func (this *RemoteHandler) getTrackManager(trackType string) *remote.TrackManage
 // ServeTrackPlaylist generates video or audio track playlists
 func (this *RemoteHandler) ServeTrackPlaylist(ctx context.Context, trackType string) (string, error) {
 	trackManager := this.getTrackManager(trackType)
-	playlist := *trackManager.Manifest
-	playlist.Segments = make([]*hls.Segment, len(trackManager.Manifest.Segments))
+	source, first := trackManager.Window()
+	playlist := *source
+	// Live playlists slide over the segments still listed by the source;
+	// the sequence number of a segment is its index
+	playlist.MediaSequence = first
+	playlist.Segments = make([]*hls.Segment, 0, len(source.Segments)-first)
+
+	// Segments are re-muxed, so the source's init segment does not apply
+	playlist.Map = nil
+	if this.Options.FragmentedMP4() {
+		playlist.Version = max(playlist.Version, 6)
+		playlist.Map = &hls.Map{URI: fmt.Sprintf("%s/%s/init.mp4", this.URLPrefix, trackType)}
+	}
 
 	cumulativeTime := 0.0
-	baseTime := time.Now()
+	baseTime := trackManager.LoadedAt
 
-	for index, segment := range trackManager.Manifest.Segments {
+	for index, segment := range source.Segments {
+		if index < first {
+			cumulativeTime += segment.Duration
+			continue
+		}
 		copy := *segment
 		// Add program date time for each segment to help with sync
 		segmentTime := baseTime.Add(time.Duration(cumulativeTime * float64(time.Second)))
 		copy.ProgramDateTime = segmentTime.Format(time.RFC3339Nano)
-		copy.URI = urlhelper.UPrintf("/%s/segment_%d.ts", trackType, index)
-		playlist.Segments[index] = &copy
+		copy.URI = urlhelper.UPrintf("%s/%s/segment_%d.%s", this.URLPrefix, trackType, index, this.Options.SegmentExtension())
+		// Segments are decrypted, cut and prefixed with their init section
+		// when downloaded
+		copy.Key = nil
+		copy.ByteRange = nil
+		copy.Map = nil
+		playlist.Segments = append(playlist.Segments, &copy)
 		cumulativeTime += segment.Duration
 	}
 
@@ -111,12 +224,22 @@ func (this *RemoteHandler) ServeTrackPlaylist(ctx context.Context, trackType str
 func (this *RemoteHandler) ServeSegment(ctx context.Context, trackType string, segmentIndex int) (*mix.FileOrBuffer, error) {
 	logger.Logger.Info("Proxying request", "type", trackType, "segment", segmentIndex)
 
+	segmentPath, err := this.getSegmentPath(trackType, segmentIndex)
+	if err != nil {
+		return nil, err
+	}
+	return segmentJobs.Do(ctx, segmentPath, func(ctx context.Context) (*mix.FileOrBuffer, error) {
+		return this.serveSegment(ctx, trackType, segmentIndex)
+	})
+}
+
+func (this *RemoteHandler) serveSegment(ctx context.Context, trackType string, segmentIndex int) (*mix.FileOrBuffer, error) {
 	if this.Options.NoTranscodeCache {
 		segment, err := this.getTrackManager(trackType).GetSegment(ctx, segmentIndex)
 		if err != nil {
 			return nil, fmt.Errorf("failed to ensure raw segment exists: %w", err)
 		}
-		return this.transcodeSegment(ctx, segment, mix.BufferTarget())
+		return this.transcodeSegment(ctx, trackType, segment, mix.BufferTarget())
 	}
 
 	transcodedPath, err := this.ensureSegmentExistsTranscoded(ctx, trackType, segmentIndex)
@@ -135,7 +258,7 @@ func (this *RemoteHandler) ensureSegmentExistsTranscoded(ctx context.Context, tr
 
 	// Load manifest and check if segment needs regeneration
 	manifest, err := ffmpeg.LoadSegmentManifest(transcodedPath + ".json")
-	needsRegeneration := err != nil || !ffmpeg.ManifestMatches(manifest, this.Options, 0)
+	needsRegeneration := err != nil || !ffmpeg.ManifestMatches(manifest, this.Options, 0, nil, this.styledSubtitle())
 
 	if _, err := os.Stat(transcodedPath); err == nil && !needsRegeneration {
 		return transcodedPath, nil
@@ -146,27 +269,60 @@ func (this *RemoteHandler) ensureSegmentExistsTranscoded(ctx context.Context, tr
 		return "", errors.Wrapf(err, "failed to ensure raw segment exists for segment %d of track %s_%d", segmentIndex, trackType, trackIndex)
 	}
 
-	_, err = this.transcodeSegment(ctx, segment, mix.FileTarget(transcodedPath))
+	_, err = this.transcodeSegment(ctx, trackType, segment, mix.FileTarget(transcodedPath))
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to transcode segment %d of track %s_%d", segmentIndex, trackType, trackIndex)
 	}
 	return transcodedPath, nil
 }
 
-func (this *RemoteHandler) transcodeSegment(ctx context.Context, input *mix.FileOrBuffer, target *mix.TargetFileOrBuffer) (*mix.FileOrBuffer, error) {
+// ServeInitSegment returns the fMP4 init segment of a track. It is written
+// alongside every transcoded segment; serving the first listed segment makes
+// sure it matches the current options.
+func (this *RemoteHandler) ServeInitSegment(ctx context.Context, trackType string) (*mix.FileOrBuffer, error) {
+	if !this.Options.FragmentedMP4() {
+		return nil, fmt.Errorf("init segments are only served for fMP4 output")
+	}
+	_, first := this.getTrackManager(trackType).Window()
+	if _, err := this.ServeSegment(ctx, trackType, first); err != nil {
+		return nil, err
+	}
+	trackDir, err := this.getTrackDir(trackType)
+	if err != nil {
+		return nil, err
+	}
+	return mix.File(filepath.Join(trackDir, "init.mp4")), nil
+}
+
+// styledSubtitle reports whether the burned in subtitle keeps its styles.
+// Remote tracks are WebVTT; only external ASS/SSA files do.
+func (this *RemoteHandler) styledSubtitle() bool {
+	path, found := GetExternalPath(this.Options.Subtitle.Path)
+	return found && isStyledSubtitleFile(path)
+}
+
+func (this *RemoteHandler) transcodeSegment(ctx context.Context, trackType string, input *mix.FileOrBuffer, target *mix.TargetFileOrBuffer) (*mix.FileOrBuffer, error) {
 	var subtitle *ffmpeg.SubtitleTranscodeOptions = nil
 
-	if this.Options.Subtitle.BurnIn {
-		path := filepath.Join(this.StorageDirectory, "subtitles.vtt")
-		_, err := this.getSubtitles(mix.FileTarget(path))
-		if err != nil {
-			return nil, fmt.Errorf("failed to get subtitles for burn-in: %w", err)
-		}
+	if this.Options.Subtitle.BurnsIn() {
 		subtitle = &ffmpeg.SubtitleTranscodeOptions{
-			Path:     path,
-			FontSize: this.Options.Subtitle.FontSize,
-			Bold:     this.Options.Subtitle.Bold,
-			Italic:   this.Options.Subtitle.Italic,
+			Source:     this.Options.Subtitle.Path,
+			FontSize:   this.Options.Subtitle.FontSize,
+			Bold:       this.Options.Subtitle.Bold,
+			Italic:     this.Options.Subtitle.Italic,
+			ForceStyle: this.Options.Subtitle.ForceStyle,
+			Delay:      this.Options.Subtitle.DelaySeconds,
+		}
+		if this.styledSubtitle() {
+			subtitle.Path, _ = GetExternalPath(this.Options.Subtitle.Path)
+			subtitle.Styled = true
+		} else {
+			path := filepath.Join(this.StorageDirectory, "subtitles.vtt")
+			_, err := this.getSubtitles(mix.FileTarget(path))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get subtitles for burn-in: %w", err)
+			}
+			subtitle.Path = path
 		}
 	}
 
@@ -176,6 +332,19 @@ func (this *RemoteHandler) transcodeSegment(ctx context.Context, input *mix.File
 		Subtitle:       subtitle,
 		Bitrate:        this.Options.Bitrate,
 		MaxOutputWidth: this.Options.MaxOutputWidth,
+		CopyVideo:      this.Options.VideoCopied(),
+		CopyAudio:      this.Options.AudioCopied(),
+		Audio:          this.Options.Audio,
+		Picture:        this.Options.Picture,
+		ToneMap:        toneMapOptions(this.Options),
+	}
+	if this.Options.FragmentedMP4() {
+		trackDir, err := this.getTrackDir(trackType)
+		if err != nil {
+			return nil, err
+		}
+		opts.Container = options.ContainerFMP4
+		opts.InitSegmentPath = filepath.Join(trackDir, "init.mp4")
 	}
 	output, err := ffmpeg.TranscodeSegment(ctx, input, target, opts)
 	if err != nil {
@@ -201,7 +370,7 @@ func (this *RemoteHandler) getSegmentPath(trackType string, segmentIndex int) (s
 		return "", err
 	}
 
-	localPath := filepath.Join(trackDir, fmt.Sprintf("segment_%d.ts", segmentIndex))
+	localPath := filepath.Join(trackDir, fmt.Sprintf("segment_%d.%s", segmentIndex, this.Options.SegmentExtension()))
 	return localPath, nil
 }
 
@@ -214,13 +383,29 @@ func (this *RemoteHandler) getTrackIndex(trackType string) int {
 
 func (this *RemoteHandler) getTrackDir(trackType string) (string, error) {
 	trackIndex := this.getTrackIndex(trackType)
-	trackDir := filepath.Join(this.StorageDirectory, fmt.Sprintf("%s_%d", trackType, trackIndex))
+	trackDir := filepath.Join(this.SegmentDirectory, fmt.Sprintf("%s_%d", trackType, trackIndex))
 	if err := os.MkdirAll(trackDir, 0755); err != nil {
 		return "", err
 	}
 	return trackDir, nil
 }
 
+// clearTranscodedSegments removes the transcoded segments of the handler and
+// its renditions, keeping the downloaded ones
+func (this *RemoteHandler) clearTranscodedSegments() {
+	for _, handler := range append([]*RemoteHandler{this}, this.renditions...) {
+		for _, trackType := range []string{"video", "audio"} {
+			trackDir := filepath.Join(handler.SegmentDirectory, fmt.Sprintf("%s_%d", trackType, handler.getTrackIndex(trackType)))
+			matches, _ := filepath.Glob(filepath.Join(trackDir, "segment_*"))
+			for _, match := range matches {
+				if !strings.HasSuffix(match, "_raw.ts") {
+					os.Remove(match)
+				}
+			}
+		}
+	}
+}
+
 // serveFile serves a local file
 func (this *RemoteHandler) serveFile(w http.ResponseWriter, path string, contentType string) {
 	data, err := os.ReadFile(path)
@@ -243,6 +428,17 @@ func (this *RemoteHandler) serveFile(w http.ResponseWriter, path string, content
 // style, timing offset) so subsequent subtitle/segment serving uses them.
 func (this *RemoteHandler) UpdateSubtitleOptions(opts options.SubtitleCastOptions) {
 	this.Options.Subtitle = opts
+	for _, rendition := range this.renditions {
+		rendition.UpdateSubtitleOptions(opts)
+	}
+}
+
+// Rendition returns the handler of an ABR rendition, numbered from 1
+func (this *RemoteHandler) Rendition(index int) (StreamHandler, error) {
+	if index < 1 || index > len(this.renditions) {
+		return nil, fmt.Errorf("rendition %d not found", index)
+	}
+	return this.renditions[index-1], nil
 }
 
 // ServeSubtitles returns the subtitle file in WebVTT format
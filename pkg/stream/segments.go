@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"wails-cast/pkg/ffmpeg"
+	"wails-cast/pkg/filehelper"
+	"wails-cast/pkg/logger"
+)
+
+// SegmentRange is one local segment on the source timeline
+type SegmentRange struct {
+	Start    float64
+	Duration float64
+}
+
+// SegmentMap is the keyframe-aligned segmentation of a local file. It is
+// persisted in the media's cache folder since probing keyframes reads the
+// whole file.
+type SegmentMap struct {
+	SourceSize     int64
+	SourceModTime  int64
+	VideoStream    int
+	TargetDuration float64
+	Segments       []SegmentRange
+}
+
+// LoadSegmentMap returns the cached segment map for a file, planning a new
+// one when the cache is missing or the file changed. If keyframes cannot be
+// probed the file is cut on a fixed grid instead.
+func LoadSegmentMap(videoPath string, storageDirectory string, videoStream int, duration float64, target float64) *SegmentMap {
+	mapPath := filepath.Join(storageDirectory, fmt.Sprintf("segment_map_%d.json", videoStream))
+
+	stat, statErr := os.Stat(videoPath)
+	if statErr == nil {
+		cached, err := filehelper.ReadJson[SegmentMap](mapPath)
+		if err == nil && cached.SourceSize == stat.Size() &&
+			cached.SourceModTime == stat.ModTime().Unix() &&
+			cached.TargetDuration == target &&
+			len(cached.Segments) > 0 {
+			return cached
+		}
+	}
+
+	keyframes, err := ffmpeg.GetKeyframes(videoPath, videoStream)
+	if err != nil {
+		logger.Logger.Warn("Failed to probe keyframes, using fixed segments", "path", videoPath, "error", err)
+	}
+
+	segmentMap := &SegmentMap{
+		VideoStream:    videoStream,
+		TargetDuration: target,
+		Segments:       PlanSegments(keyframes, duration, target),
+	}
+
+	if statErr == nil && err == nil {
+		segmentMap.SourceSize = stat.Size()
+		segmentMap.SourceModTime = stat.ModTime().Unix()
+		filehelper.WriteJson(mapPath, segmentMap)
+	}
+
+	return segmentMap
+}
+
+// PlanSegments cuts [0, duration) at the first keyframe at or after every
+// target interval, so each segment starts on a keyframe. Without keyframes it
+// falls back to a fixed target grid.
+func PlanSegments(keyframes []float64, duration float64, target float64) []SegmentRange {
+	segments := make([]SegmentRange, 0)
+	if duration <= 0 || target <= 0 {
+		return segments
+	}
+
+	// Cut points after the start of the file
+	var cuts []float64
+	if len(keyframes) == 0 {
+		for t := target; t < duration; t += target {
+			cuts = append(cuts, t)
+		}
+	} else {
+		next := target
+		for _, keyframe := range keyframes {
+			if keyframe >= duration {
+				break
+			}
+			if keyframe >= next {
+				cuts = append(cuts, keyframe)
+				next = keyframe + target
+			}
+		}
+	}
+
+	start := 0.0
+	for _, cut := range cuts {
+		segments = append(segments, SegmentRange{Start: start, Duration: cut - start})
+		start = cut
+	}
+	segments = append(segments, SegmentRange{Start: start, Duration: duration - start})
+
+	return segments
+}
+
+// MaxDuration returns the longest segment duration rounded up, as required
+// for #EXT-X-TARGETDURATION
+func (m *SegmentMap) MaxDuration() int {
+	longest := 0.0
+	for _, segment := range m.Segments {
+		longest = math.Max(longest, segment.Duration)
+	}
+	return int(math.Ceil(longest))
+}
@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlanSegments(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyframes []float64
+		duration  float64
+		target    float64
+		want      []SegmentRange
+	}{
+		{name: "no duration", duration: 0, target: 8, want: []SegmentRange{}},
+		{name: "no target", duration: 20, target: 0, want: []SegmentRange{}},
+		{
+			name:     "fixed grid",
+			duration: 20, target: 8,
+			want: []SegmentRange{{0, 8}, {8, 8}, {16, 4}},
+		},
+		{
+			name:     "fixed grid of a whole number of segments",
+			duration: 16, target: 8,
+			want: []SegmentRange{{0, 8}, {8, 8}},
+		},
+		{
+			name:     "shorter than the target",
+			duration: 5, target: 8,
+			want: []SegmentRange{{0, 5}},
+		},
+		{
+			name:      "cuts on the first keyframe after each target",
+			keyframes: []float64{0, 2.5, 5, 7.5, 10, 12.5, 15, 17.5},
+			duration:  20, target: 8,
+			want: []SegmentRange{{0, 10}, {10, 10}},
+		},
+		{
+			name:      "keyframes on the target",
+			keyframes: []float64{0, 4, 8, 12, 16, 20},
+			duration:  22, target: 8,
+			want: []SegmentRange{{0, 8}, {8, 8}, {16, 6}},
+		},
+		{
+			name:      "long gaps between keyframes",
+			keyframes: []float64{0, 30, 31},
+			duration:  40, target: 8,
+			want: []SegmentRange{{0, 30}, {30, 10}},
+		},
+		{
+			name:      "keyframes after the end are ignored",
+			keyframes: []float64{0, 8, 16, 24},
+			duration:  16, target: 8,
+			want: []SegmentRange{{0, 8}, {8, 8}},
+		},
+		{
+			name:      "only the first keyframe",
+			keyframes: []float64{0},
+			duration:  30, target: 8,
+			want: []SegmentRange{{0, 30}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PlanSegments(tt.keyframes, tt.duration, tt.target)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("PlanSegments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentMapMaxDuration(t *testing.T) {
+	tests := []struct {
+		segments []SegmentRange
+		want     int
+	}{
+		{segments: nil, want: 0},
+		{segments: []SegmentRange{{0, 8}, {8, 8}}, want: 8},
+		{segments: []SegmentRange{{0, 8}, {8, 10.01}, {18.01, 2}}, want: 11},
+	}
+	for _, tt := range tests {
+		segmentMap := &SegmentMap{Segments: tt.segments}
+		if got := segmentMap.MaxDuration(); got != tt.want {
+			t.Errorf("MaxDuration(%v) = %d, want %d", tt.segments, got, tt.want)
+		}
+	}
+}
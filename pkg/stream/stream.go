@@ -21,6 +21,10 @@ type StreamHandler interface {
 	// Returns the file path to serve and any error encountered
 	ServeSegment(ctx context.Context, trackType string, segmentIndex int) (*mix.FileOrBuffer, error)
 
+	// ServeInitSegment returns the fMP4 init segment (EXT-X-MAP) of a track
+	// when segments are served as fragmented MP4
+	ServeInitSegment(ctx context.Context, trackType string) (*mix.FileOrBuffer, error)
+
 	// ServeSubtitles returns the subtitle file in WebVTT format
 	// Returns the subtitle content as string and any error encountered
 	ServeSubtitles(ctx context.Context) (*mix.FileOrBuffer, error)
@@ -28,4 +32,8 @@ type StreamHandler interface {
 	// UpdateSubtitleOptions replaces the subtitle options used for live
 	// rendering (path, font size, style, timing offset) without recasting.
 	UpdateSubtitleOptions(opts options.SubtitleCastOptions)
+
+	// Rendition returns the handler serving an ABR rendition below the
+	// original stream, numbered from 1 (/rendition_{n}/...)
+	Rendition(index int) (StreamHandler, error)
 }
@@ -3,6 +3,7 @@ package urlhelper
 import (
 	"fmt"
 	"net/url"
+	"strings"
 )
 
 func UPrintf(format string, args ...any) *url.URL {
@@ -16,3 +17,8 @@ func ParseFixed(rawurl string) *url.URL {
 	}
 	return url
 }
+
+// IsURL reports whether a media path is an http(s) URL rather than a file
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
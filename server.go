@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 	"wails-cast/pkg/events"
@@ -17,6 +18,7 @@ type Server struct {
 	port          int
 	subtitlePath  string
 	streamHandler stream.StreamHandler
+	prefetcher    *stream.Prefetcher
 	httpServer    *http.Server
 	seekTime      int
 	mu            sync.RWMutex
@@ -54,6 +56,15 @@ func (s *Server) SetHandler(handler stream.StreamHandler) {
 	logger.Info("Server handler set")
 }
 
+// SetPrefetcher replaces the look-ahead transcoder, stopping the previous one
+func (s *Server) SetPrefetcher(prefetcher *stream.Prefetcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prefetcher.Stop()
+	s.prefetcher = prefetcher
+}
+
 // SetSubtitlePath sets the subtitle file
 func (s *Server) SetSubtitlePath(path string) {
 	s.mu.Lock()
@@ -93,6 +104,7 @@ func (s *Server) Start() error {
 
 // Stop stops the HTTP server
 func (s *Server) Stop() error {
+	s.SetPrefetcher(nil)
 
 	if s.httpServer != nil {
 		return s.httpServer.Close()
@@ -115,6 +127,18 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	inhibitor.Refresh()
 
+	// ABR renditions: /rendition_{n}/... is served by the rendition's handler
+	var renditionIndex int
+	if _, err := fmt.Sscanf(path, "/rendition_%d/", &renditionIndex); err == nil {
+		rendition, err := handler.Rendition(renditionIndex)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		handler = rendition
+		path = path[strings.Index(path[1:], "/")+1:]
+	}
+
 	// Main playlist: /playlist.m3u8 or /media.mp4
 	if path == "/playlist.m3u8" {
 		playlist, err := handler.ServeManifestPlaylist(r.Context())
@@ -159,48 +183,28 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	var segmentIndex int
 
-	// Video segments: /video/segment_{i}.ts
-	if _, err := fmt.Sscanf(path, "/video/segment_%d.ts", &segmentIndex); err == nil {
-		shouldReturn := EnsureRequestDuration(r)
-		if shouldReturn {
-			return
-		}
-
-		buffer, err := handler.ServeSegment(r.Context(), "video", segmentIndex)
-		if err != nil {
-			s.handleError(w, r, "Failed to generate video segment", err)
-			return
-		}
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Content-Type", "video/mp2t")
-		w.Header().Set("Accept-Ranges", "bytes")
-		w.Header().Set("Cache-Control", "public, max-age=31536000")
-		buffer.Serve(w, r)
-		return
-	}
-
-	// Audio segments: /audio/segment_{i}.ts
-	if _, err := fmt.Sscanf(path, "/audio/segment_%d.ts", &segmentIndex); err == nil {
-		shouldReturn := EnsureRequestDuration(r)
-		if shouldReturn {
-			return
+	// Segments: /{video,audio}/segment_{i}.{ts,m4s}
+	for _, trackType := range []string{"video", "audio"} {
+		for extension, contentType := range segmentContentTypes {
+			if _, err := fmt.Sscanf(path, "/"+trackType+"/segment_%d."+extension, &segmentIndex); err == nil {
+				s.serveSegment(w, r, handler, trackType, segmentIndex, contentType)
+				return
+			}
 		}
 
-		buffer, err := handler.ServeSegment(r.Context(), "audio", segmentIndex)
-		if err != nil {
-			s.handleError(w, r, "Failed to generate audio segment", err)
+		// fMP4 init segments: /{video,audio}/init.mp4
+		if path == "/"+trackType+"/init.mp4" {
+			buffer, err := handler.ServeInitSegment(r.Context(), trackType)
+			if err != nil {
+				s.handleError(w, r, "Failed to generate "+trackType+" init segment", err)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Header().Set("Cache-Control", "no-cache")
+			buffer.Serve(w, r)
 			return
 		}
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Content-Type", "video/mp2t")
-		w.Header().Set("Accept-Ranges", "bytes")
-		w.Header().Set("Cache-Control", "public, max-age=31536000")
-		buffer.Serve(w, r)
-		return
 	}
 
 	// Subtitles: /subtitles.vtt
@@ -228,6 +232,38 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
 }
 
+// segmentContentTypes maps served segment extensions to their MIME types
+var segmentContentTypes = map[string]string{
+	"ts":  "video/mp2t",
+	"m4s": "video/iso.segment",
+}
+
+// serveSegment transcodes and writes one media segment
+func (s *Server) serveSegment(w http.ResponseWriter, r *http.Request, handler stream.StreamHandler, trackType string, segmentIndex int, contentType string) {
+	shouldReturn := EnsureRequestDuration(r)
+	if shouldReturn {
+		return
+	}
+
+	s.mu.RLock()
+	prefetcher := s.prefetcher
+	s.mu.RUnlock()
+	prefetcher.Advance(handler, trackType, segmentIndex)
+
+	buffer, err := handler.ServeSegment(r.Context(), trackType, segmentIndex)
+	if err != nil {
+		s.handleError(w, r, "Failed to generate "+trackType+" segment", err)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Cache-Control", "public, max-age=31536000")
+	buffer.Serve(w, r)
+}
+
 func EnsureRequestDuration(r *http.Request) bool {
 	select {
 	case <-r.Context().Done():
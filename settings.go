@@ -7,7 +7,10 @@ import (
 	"path/filepath"
 	"sync"
 
+	"wails-cast/pkg/ffmpeg"
 	"wails-cast/pkg/folders"
+	"wails-cast/pkg/options"
+	"wails-cast/pkg/stream"
 )
 
 const (
@@ -26,6 +29,25 @@ func getDefaultSettings() Settings {
 		DefaultQuality:             "5M",
 		SubtitleFontSize:           24,
 		MaxOutputWidth:             0,
+		VideoEncoder:               ffmpeg.EncoderAuto,
+		DirectPlay:                 true,
+		SegmentContainer:           options.ContainerMPEGTS,
+		AdaptiveBitrate:            false,
+		Deinterlace:                "off",
+		AutoCrop:                   false,
+		PrefetchSegments:           3,
+		PrefetchWorkers:            2,
+		MaxFFmpegProcesses:         4,
+		DownloadParallelism:        4,
+		DownloadRateLimit:          0,
+		DownloadWindowStart:        "",
+		DownloadWindowEnd:          "",
+		AudioLoudnorm:              false,
+		AudioNightMode:             false,
+		AudioDialogueBoost:         false,
+		AudioBitrate:               "96k",
+		DeviceAudioModes:           map[string]string{},
+		DeviceKeepHDR:              map[string]bool{},
 		TranslatePromptTemplate:    "Create a subtitle translation in {{.TargetLanguage}} based on the references in other languages.\nMultiple language tracks from the same video are provided as reference to help you understand context and maintain consistent terminology.\n\nInput format:\ndelay: <seconds>\nduration: <seconds>\n<text>\n\n{{.SubtitleContent}}\n\nOutput the translation in the same format inside <llm_output></llm_output> tags.",
 		MaxSubtitleSamples:         4,
 		NoTranscodeCache:           false,
@@ -70,8 +92,59 @@ type Settings struct {
 	// SubtitleBold / SubtitleItalic style rendered subtitles.
 	SubtitleBold   bool `json:"subtitleBold"`
 	SubtitleItalic bool `json:"subtitleItalic"`
+	// SubtitleForceStyle applies the font size and style above to burned-in
+	// ASS/SSA subtitles instead of keeping their own styles.
+	SubtitleForceStyle bool `json:"subtitleForceStyle"`
+
+	MaxOutputWidth int `json:"maxOutputWidth"`
+	// VideoEncoder is the ffmpeg H.264 encoder used for transcoding, or
+	// "auto" to pick the first working hardware encoder (libx264 fallback).
+	VideoEncoder string `json:"videoEncoder"`
+	// DirectPlay stream-copies sources the receiver can decode natively
+	// instead of re-encoding them.
+	DirectPlay bool `json:"directPlay"`
+	// SegmentContainer is "mpegts" or "fmp4" (fragmented MP4 / CMAF with an
+	// EXT-X-MAP init segment).
+	SegmentContainer string `json:"segmentContainer"`
+	// AdaptiveBitrate publishes lower-bitrate renditions (options.DefaultLadder)
+	// next to the original stream so the receiver can step down on a weak link.
+	AdaptiveBitrate bool `json:"adaptiveBitrate"`
+	// Deinterlace is the filter (bwdif, yadif or "off") applied to local
+	// files detected as interlaced; AutoCrop removes detected black bars.
+	// Both scan the file before its first cast, so they are off by default.
+	Deinterlace string `json:"deinterlace"`
+	AutoCrop    bool   `json:"autoCrop"`
+	// PrefetchSegments is how many segments ahead of the playhead are
+	// transcoded in the background by PrefetchWorkers workers (0 = off).
+	PrefetchSegments int `json:"prefetchSegments"`
+	PrefetchWorkers  int `json:"prefetchWorkers"`
+	// MaxFFmpegProcesses caps simultaneous ffmpeg transcodes (0 = unlimited).
+	MaxFFmpegProcesses int `json:"maxFfmpegProcesses"`
+	// DownloadParallelism is how many segments of a remote track are
+	// downloaded at once.
+	DownloadParallelism int `json:"downloadParallelism"`
+	// DownloadRateLimit caps background downloads in KB/s (0 = unlimited).
+	// They only run between DownloadWindowStart and DownloadWindowEnd
+	// ("HH:MM", empty = any time).
+	DownloadRateLimit   int    `json:"downloadRateLimit"`
+	DownloadWindowStart string `json:"downloadWindowStart"`
+	DownloadWindowEnd   string `json:"downloadWindowEnd"`
+
+	// Audio processing applied when audio is transcoded. Loudnorm is EBU
+	// R128 normalization, night mode compresses the dynamic range and
+	// dialogue boost raises the center channel in the stereo downmix.
+	AudioLoudnorm      bool   `json:"audioLoudnorm"`
+	AudioNightMode     bool   `json:"audioNightMode"`
+	AudioDialogueBoost bool   `json:"audioDialogueBoost"`
+	AudioBitrate       string `json:"audioBitrate"`
+	// DeviceAudioModes maps a device UUID (its host when it has none) to its
+	// audio mode (stereo, surround-aac, surround-ac3 or passthrough); unlisted
+	// devices get stereo.
+	DeviceAudioModes map[string]string `json:"deviceAudioModes"`
+	// DeviceKeepHDR lists devices that display HDR, keyed like
+	// DeviceAudioModes; HDR sources are tone-mapped to SDR for all others.
+	DeviceKeepHDR map[string]bool `json:"deviceKeepHdr"`
 
-	MaxOutputWidth          int    `json:"maxOutputWidth"`
 	TranslatePromptTemplate string `json:"translatePromptTemplate"`
 	MaxSubtitleSamples      int    `json:"maxSubtitleSamples"`
 	NoTranscodeCache        bool   `json:"noTranscodeCache"`
@@ -105,6 +178,74 @@ type legacySettings struct {
 	OpenAICompatModel   string `json:"openAICompatModel"`
 }
 
+// renditions returns the ABR ladder offered when casting
+func (s Settings) renditions() []options.Rendition {
+	if !s.AdaptiveBitrate {
+		return nil
+	}
+	return options.DefaultLadder
+}
+
+// picture returns the picture adjustments of a cast: the automatic filters
+// from the settings and the manual overrides picked for the media
+func (s Settings) picture(castOptions *options.CastOptions) options.PictureOptions {
+	picture := options.PictureOptions{
+		Deinterlace: s.Deinterlace,
+		AutoCrop:    s.AutoCrop,
+		AspectRatio: castOptions.AspectRatio,
+		Zoom:        castOptions.Zoom,
+	}
+	if picture.Deinterlace == "off" {
+		picture.Deinterlace = ""
+	}
+	return picture
+}
+
+// subtitle returns the subtitle rendering of a cast or export of path
+func (s Settings) subtitle(path string) options.SubtitleCastOptions {
+	return options.SubtitleCastOptions{
+		Path:                 path,
+		BurnIn:               s.SubtitleBurnIn,
+		FontSize:             s.SubtitleFontSize,
+		IgnoreClosedCaptions: s.IgnoreClosedCaptions,
+		DelaySeconds:         s.SubtitleDelaySeconds,
+		Bold:                 s.SubtitleBold,
+		Italic:               s.SubtitleItalic,
+		ForceStyle:           s.SubtitleForceStyle,
+	}
+}
+
+// audioMode returns the audio mode configured for a device, keyed by
+// DeviceDiscovery.DeviceKey. Modes saved under the host before devices were
+// keyed by UUID still apply.
+func (s Settings) audioMode(deviceKey string, deviceHost string) string {
+	if mode, found := s.DeviceAudioModes[deviceKey]; found {
+		return mode
+	}
+	if mode, found := s.DeviceAudioModes[deviceHost]; found {
+		return mode
+	}
+	return options.AudioModeStereo
+}
+
+// keepHDR reports whether HDR video is passed through to a device, keyed
+// like audioMode
+func (s Settings) keepHDR(deviceKey string, deviceHost string) bool {
+	if keep, found := s.DeviceKeepHDR[deviceKey]; found {
+		return keep
+	}
+	return s.DeviceKeepHDR[deviceHost]
+}
+
+// prefetcher returns the look-ahead transcoder used while casting. Prefetched
+// segments land in the transcoding cache, so it is off without one.
+func (s Settings) prefetcher() *stream.Prefetcher {
+	if s.NoTranscodeCache {
+		return nil
+	}
+	return stream.NewPrefetcher(s.PrefetchSegments, s.PrefetchWorkers)
+}
+
 type SettingsStore struct {
 	settings Settings
 	filePath string